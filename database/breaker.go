@@ -0,0 +1,296 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// Statements run normally
+	BreakerClosed BreakerState = iota
+	// Statements fail fast with ErrCircuitOpen
+	BreakerOpen
+	// A single probe statement runs to test the database
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig configures a CircuitBreaker.
+type BreakerConfig struct {
+	// Consecutive failures that open the circuit. Defaults to 5.
+	FailureThreshold int
+	// Time the circuit stays open before a probe is let through.
+	// Defaults to 30s.
+	OpenTimeout time.Duration
+	// Reports whether err means the database is degraded.
+	// Defaults to IsUnavailable.
+	IsFailure func(err error) bool
+	// Called when the circuit changes state, e.g. to export a gauge.
+	OnStateChange func(from, to BreakerState)
+	// Called when a statement is rejected with ErrCircuitOpen.
+	OnReject func()
+}
+
+// CircuitBreaker is a gorm plugin failing statements fast with
+// ErrCircuitOpen after repeated failures of the database, instead of letting
+// them wait on the connection pool.
+//
+//	db, err := database.Connect(dsn, database.PG, database.WithCircuitBreaker(database.BreakerConfig{}))
+//
+// After OpenTimeout, a single probe statement is let through: the circuit
+// closes if it succeeds and opens again if it fails.
+type CircuitBreaker struct {
+	config BreakerConfig
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	// Incremented on every change of state
+	epoch uint64
+	// Changes of state not yet passed to OnStateChange
+	changes []stateChange
+}
+
+type stateChange struct {
+	from, to BreakerState
+}
+
+// Ticket identifies a statement allowed by a CircuitBreaker.
+type Ticket struct {
+	// State changes before the statement was allowed
+	epoch uint64
+	// The statement is the probe of a half-open circuit
+	probe bool
+}
+
+// WithCircuitBreaker returns an Option guarding every statement of the
+// connection with a new CircuitBreaker. CircuitBreakerOf returns it.
+func WithCircuitBreaker(config BreakerConfig) Option {
+	return func(db *gorm.DB) error {
+		return db.Use(NewCircuitBreaker(config))
+	}
+}
+
+// CircuitBreakerOf returns the circuit breaker of db, if it uses one.
+func CircuitBreakerOf(db *gorm.DB) (*CircuitBreaker, bool) {
+	b, ok := db.Config.Plugins[breakerName].(*CircuitBreaker)
+	return b, ok
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker.
+func NewCircuitBreaker(config BreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+
+	if config.IsFailure == nil {
+		config.IsFailure = IsUnavailable
+	}
+
+	return &CircuitBreaker{config: config}
+}
+
+// IsUnavailable returns true if err is a dropped or refused connection or a
+// timeout.
+func IsUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Allow returns ErrCircuitOpen if a statement may not run now.
+// Every allowed statement must be followed by a call to Report with the
+// returned ticket.
+func (b *CircuitBreaker) Allow() (Ticket, error) {
+	b.mu.Lock()
+
+	var err error
+	var probe bool
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			err = ErrCircuitOpen
+			break
+		}
+
+		b.setState(BreakerHalfOpen)
+		b.probing, probe = true, true
+	case BreakerHalfOpen:
+		if b.probing {
+			err = ErrCircuitOpen
+		} else {
+			b.probing, probe = true, true
+		}
+	}
+
+	ticket := Ticket{epoch: b.epoch, probe: probe}
+	b.unlock()
+
+	if err != nil && b.config.OnReject != nil {
+		b.config.OnReject()
+	}
+	return ticket, err
+}
+
+// Report records the outcome of the statement allowed with ticket.
+// Statements allowed before the last change of state are ignored, so only
+// the probe resolves a half-open circuit.
+func (b *CircuitBreaker) Report(ticket Ticket, err error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if ticket.epoch != b.epoch {
+		return
+	}
+
+	failed := err != nil && b.config.IsFailure(err)
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if !ticket.probe {
+			return
+		}
+
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.failures = 0
+			b.setState(BreakerClosed)
+		}
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.open()
+		}
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = time.Now()
+	b.setState(BreakerOpen)
+}
+
+// Changes the state, recording the change for OnStateChange. b.mu must be
+// held.
+func (b *CircuitBreaker) setState(to BreakerState) {
+	if b.state != to {
+		b.changes = append(b.changes, stateChange{from: b.state, to: to})
+	}
+
+	b.state = to
+	b.epoch++
+}
+
+// Unlocks b.mu, then calls OnStateChange with the changes made while it was
+// held, so the hook may use the breaker.
+func (b *CircuitBreaker) unlock() {
+	changes := b.changes
+	b.changes = nil
+	b.mu.Unlock()
+
+	if b.config.OnStateChange == nil {
+		return
+	}
+
+	for _, c := range changes {
+		b.config.OnStateChange(c.from, c.to)
+	}
+}
+
+const breakerName = "realorm:circuit_breaker"
+
+// Name implements gorm.Plugin.
+func (b *CircuitBreaker) Name() string {
+	return breakerName
+}
+
+// Initialize implements gorm.Plugin by guarding every statement of db.
+// Dry run statements never reach the database and are not guarded.
+func (b *CircuitBreaker) Initialize(db *gorm.DB) error {
+	const name = breakerName
+
+	before := func(db *gorm.DB) {
+		if db.DryRun {
+			return
+		}
+
+		ticket, err := b.Allow()
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		db.InstanceSet(name, ticket)
+	}
+
+	after := func(db *gorm.DB) {
+		if ticket, ok := db.InstanceGet(name); ok {
+			b.Report(ticket.(Ticket), db.Error)
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register(name+"_before", before),
+		cb.Create().After("*").Register(name+"_after", after),
+		cb.Query().Before("*").Register(name+"_before", before),
+		cb.Query().After("*").Register(name+"_after", after),
+		cb.Update().Before("*").Register(name+"_before", before),
+		cb.Update().After("*").Register(name+"_after", after),
+		cb.Delete().Before("*").Register(name+"_before", before),
+		cb.Delete().After("*").Register(name+"_after", after),
+		cb.Row().Before("*").Register(name+"_before", before),
+		cb.Row().After("*").Register(name+"_after", after),
+		cb.Raw().Before("*").Register(name+"_before", before),
+		cb.Raw().After("*").Register(name+"_after", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
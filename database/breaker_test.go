@@ -0,0 +1,190 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var transitions []string
+	rejected := 0
+
+	db, err := Connect(filepath.Join(t.TempDir(), "breaker.db"), SQLITE_DEFAULT, WithCircuitBreaker(BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      50 * time.Millisecond,
+		IsFailure:        func(err error) bool { return true },
+		OnStateChange: func(from, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+		OnReject: func() { rejected++ },
+	}))
+
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+
+	breaker, ok := CircuitBreakerOf(db)
+	if !ok {
+		t.Fatalf("expected the connection to use a circuit breaker")
+	}
+
+	var n int
+	fail := func() error { return db.Raw("SELECT count(*) FROM missing").Scan(&n).Error }
+	succeed := func() error { return db.Raw("SELECT 1").Scan(&n).Error }
+
+	for i := 0; i < 2; i++ {
+		if err := fail(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected a query error, got %v", err)
+		}
+	}
+
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected the circuit to be open, got %s", breaker.State())
+	}
+
+	if err := succeed(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// a failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	if err := fail(); errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected the probe to run, got %v", err)
+	}
+
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected the circuit to be open, got %s", breaker.State())
+	}
+
+	// a successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	if err := succeed(); err != nil {
+		t.Errorf("error running probe: %v", err)
+	}
+
+	if err := succeed(); err != nil {
+		t.Errorf("error running query: %v", err)
+	}
+
+	expected := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected transitions %v, got %v", expected, transitions)
+	}
+
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("expected transitions %v, got %v", expected, transitions)
+			break
+		}
+	}
+
+	if rejected != 1 {
+		t.Errorf("expected 1 rejected statement, got %d", rejected)
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Millisecond,
+		IsFailure:        func(err error) bool { return true },
+	})
+
+	// two statements start while the circuit is closed
+	failing, _ := breaker.Allow()
+	slow, _ := breaker.Allow()
+
+	breaker.Report(failing, errors.New("connection refused"))
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected the circuit to be open, got %s", breaker.State())
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	probe, err := breaker.Allow()
+	if err != nil {
+		t.Fatalf("expected the probe to be allowed, got %v", err)
+	}
+
+	// the outcome of a statement started before the circuit opened does
+	// not resolve the probe
+	breaker.Report(slow, nil)
+	if breaker.State() != BreakerHalfOpen {
+		t.Errorf("expected the circuit to stay half-open, got %s", breaker.State())
+	}
+
+	if _, err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen while probing, got %v", err)
+	}
+
+	breaker.Report(probe, nil)
+	if breaker.State() != BreakerClosed {
+		t.Errorf("expected the probe to close the circuit, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreakerStateChangeHook(t *testing.T) {
+	var breaker *CircuitBreaker
+	var states []BreakerState
+
+	breaker = NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 1,
+		OnStateChange: func(from, to BreakerState) {
+			// the hook may read the state, e.g. to export a gauge
+			states = append(states, breaker.State())
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticket, _ := breaker.Allow()
+		breaker.Report(ticket, driver.ErrBadConn)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("OnStateChange deadlocked calling State")
+	}
+
+	if len(states) != 1 || states[0] != BreakerOpen {
+		t.Errorf("expected the hook to see the open circuit, got %v", states)
+	}
+}
+
+func TestCircuitBreakerDryRun(t *testing.T) {
+	db, err := Connect(SQLITE3_MEMORY_DB, SQLITE_DEFAULT, WithCircuitBreaker(BreakerConfig{
+		FailureThreshold: 1,
+		IsFailure:        func(err error) bool { return true },
+	}))
+
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+
+	var n int
+	if err := db.Raw("SELECT count(*) FROM missing").Scan(&n).Error; err == nil {
+		t.Fatalf("expected a query error")
+	}
+
+	breaker, _ := CircuitBreakerOf(db)
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected the circuit to be open, got %s", breaker.State())
+	}
+
+	// dry runs never reach the database, so the open circuit lets them through
+	dry := db.Session(&gorm.Session{DryRun: true})
+	var rows []struct{ ID int }
+	if err := dry.Table("missing").Find(&rows).Error; err != nil {
+		t.Errorf("expected the dry run to bypass the breaker, got %v", err)
+	}
+
+	if breaker.State() != BreakerOpen {
+		t.Errorf("expected the dry run not to probe the circuit, got %s", breaker.State())
+	}
+}
@@ -6,34 +6,52 @@ import (
 	"gorm.io/gorm"
 )
 
+// Shared in-memory database, for the SQLITE3 and SQLITE dialects alike.
 const SQLITE3_MEMORY_DB = "file::memory:?cache=shared"
 
-type config struct {
-	database string
-	user     string
-	password string
-	host     string
-	sslmode  string
-	timezone string
+// Config is the configuration of a connection: a *PostgresConfig,
+// *MySQLConfig, *SQLiteConfig or *SQLServerConfig.
+type Config interface {
+	// DSN returns the connection string of the config
+	DSN() string
+	// Validate returns an error naming the first invalid setting
+	Validate() error
+	// Dialect returns the only dialect the config is valid for
+	Dialect() DialectString
 }
 
+// Option configures a connection opened by Connect before it is returned.
+type Option func(db *gorm.DB) error
+
 // Connect to the database using the config object or dsn
 // string. It returns a pointer to the database connection
-// and an error if any.
-func Connect(connection any, dialect DialectString) (*gorm.DB, error) {
+// and an error if any. Passwords are masked in returned errors.
+func Connect(connection any, dialect DialectString, options ...Option) (*gorm.DB, error) {
 	var err error
 	var dsn string
+	var config Config
+
+	switch c := connection.(type) {
+	case Config:
+		// a config is valid only with its own dialect
+		if c.Dialect() != dialect {
+			return nil, fmt.Errorf("%T is only valid when dialect is %s", c, c.Dialect())
+		}
+
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
 
-	switch connection.(type) {
-	case *config:
-		// config is valid only if dialect is postgres
-		if dialect == PG {
-			dsn = DSNFromConfig(connection.(*config))
-		} else {
-			return nil, fmt.Errorf("config is only valid when dialect is postgres")
+		if c, ok := c.(*MySQLConfig); ok {
+			if _, err := c.RegisterTLS(); err != nil {
+				return nil, err
+			}
 		}
+
+		dsn = c.DSN()
+		config = c
 	case string:
-		dsn = connection.(string)
+		dsn = c
 	default:
 		return nil, fmt.Errorf("connection is not a valid dsn string or config")
 	}
@@ -44,18 +62,31 @@ func Connect(connection any, dialect DialectString) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	return gorm.Open(dialector, &gormConfig)
+	if config != nil {
+		d, err := customDialector(config)
+		if err != nil {
+			return nil, redactError(err, dsn)
+		}
 
-}
+		if d != nil {
+			dialector = d
+		}
+	}
 
-// Returns a pointer to the new config object
-func NewConfig(db, user, password, host, ssl_mode, tz string) *config {
-	return &config{
-		database: db,
-		user:     user,
-		password: password,
-		host:     host,
-		sslmode:  "disable",
-		timezone: tz,
+	db, err := gorm.Open(dialector, &gormConfig)
+	if err != nil {
+		// drivers may echo the dsn and its password
+		return nil, redactError(err, dsn)
 	}
+
+	for _, option := range options {
+		if err := option(db); err != nil {
+			if sqlDB, e := db.DB(); e == nil {
+				sqlDB.Close()
+			}
+			return nil, err
+		}
+	}
+	return db, nil
+
 }
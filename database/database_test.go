@@ -46,7 +46,7 @@ func TestNew(t *testing.T) {
 		},
 		{
 			connection: SQLITE3_MEMORY_DB,
-			dialect:    SQLITE3,
+			dialect:    SQLITE_DEFAULT,
 		},
 		{
 			connection: nil,
@@ -110,7 +110,7 @@ func TestParseDialect(t *testing.T) {
 		},
 		{
 			connection: SQLITE3_MEMORY_DB,
-			dialect:    SQLITE3,
+			dialect:    SQLITE_DEFAULT,
 			logLevel:   "error",
 		},
 	}
@@ -148,33 +148,33 @@ func TestParseDSN(t *testing.T) {
 	}
 
 	// test default values
-	if config.host != "localhost" {
-		t.Errorf("expected host to be localhost, got %s\n", config.host)
+	if config.Host != "localhost" {
+		t.Errorf("expected host to be localhost, got %s\n", config.Host)
 	}
 
 	// user
-	if config.user != "realorm" {
-		t.Errorf("expected user to be realorm, got %s\n", config.user)
+	if config.User != "realorm" {
+		t.Errorf("expected user to be realorm, got %s\n", config.User)
 	}
 
 	// password
-	if config.password != "password" {
-		t.Errorf("expected password to be password, got %s\n", config.password)
+	if config.Password != "password" {
+		t.Errorf("expected password to be password, got %s\n", config.Password)
 	}
 
 	// database
-	if config.database != "realorm" {
-		t.Errorf("expected database to be realorm, got %s\n", config.database)
+	if config.Database != "realorm" {
+		t.Errorf("expected database to be realorm, got %s\n", config.Database)
 	}
 
 	// sslmode
-	if config.sslmode != "disable" {
-		t.Errorf("expected sslmode to be disable, got %s\n", config.sslmode)
+	if config.SSLMode != "disable" {
+		t.Errorf("expected sslmode to be disable, got %s\n", config.SSLMode)
 	}
 
 	// timezone
-	if config.timezone != "Africa/Kampala" {
-		t.Errorf("expected timezone to be Africa/Kampala, got %s\n", config.timezone)
+	if config.TimeZone != "Africa/Kampala" {
+		t.Errorf("expected timezone to be Africa/Kampala, got %s\n", config.TimeZone)
 	}
 
 	// test empty config
@@ -195,11 +195,10 @@ func TestParseDSN(t *testing.T) {
 		}
 	}
 
-	// test dsn without password
+	// test dsn without password, left to trust auth or a password provider
 	config, err = ParseDSN("dbname=realorm user=realorm host=localhost sslmode=disable timezone=Africa/Kampala")
-	if err == nil {
-		// should fail
-		t.Errorf("expected error parsing dsn without password\n")
+	if err != nil || config.Password != "" {
+		t.Errorf("expected dsn without password to parse, got %v\n", err)
 	}
 
 	// test dsn without database
@@ -3,11 +3,11 @@ package database
 import (
 	"fmt"
 	"os"
-	"strings"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -15,89 +15,82 @@ import (
 type DialectString string
 
 const (
-	PG      DialectString = "postgres"
-	SQLITE3 DialectString = "sqlite3"
-	MYSQL   DialectString = "mysql"
+	PG        DialectString = "postgres"
+	SQLITE3   DialectString = "sqlite3" // sqlite with cgo, only registered in cgo builds
+	SQLITE    DialectString = "sqlite"  // sqlite without cgo
+	MYSQL     DialectString = "mysql"
+	SQLSERVER DialectString = "sqlserver"
 )
 
-// Constructs a DSN string from a config object c
-func (c *config) getDSN() string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=%s timezone=%s", c.host, c.user, c.password, c.database, c.sslmode, c.timezone)
+func init() {
+	RegisterDialect(PG, DialectFactory{
+		Open: postgres.Open,
+		GormConfig: func() gorm.Config {
+			return gorm.Config{
+				// Cache prepared statements to speedup performance
+				PrepareStmt:                              true,
+				DisableForeignKeyConstraintWhenMigrating: false,
+			}
+		},
+		ParseDSN: func(dsn string) (Config, error) {
+			return configOrError(ParseDSN(dsn))
+		},
+		TranslateError: translatePostgresError,
+		Features:       Features{Returning: true, Upsert: UpsertOnConflict, MaxParams: 65535},
+	})
+
+	RegisterDialect(MYSQL, DialectFactory{
+		Open: mysql.Open,
+		ParseDSN: func(dsn string) (Config, error) {
+			return configOrError(ParseMySQLDSN(dsn))
+		},
+		TranslateError: translateMySQLError,
+		Features:       Features{Upsert: UpsertOnDuplicateKey, MaxParams: 65535},
+	})
+
+	RegisterDialect(SQLITE, DialectFactory{
+		// the pure Go driver of glebarez/go-sqlite, a fork of modernc.org/sqlite
+		Open: sqlite.Open,
+		ParseDSN: func(dsn string) (Config, error) {
+			return configOrError(ParseSQLiteDSN(dsn, true))
+		},
+		TranslateError: translateSQLiteError,
+		Features:       Features{Returning: true, Upsert: UpsertOnConflict, MaxParams: 32766},
+	})
+
+	RegisterDialect(SQLSERVER, DialectFactory{
+		Open: sqlserver.Open,
+		ParseDSN: func(dsn string) (Config, error) {
+			return configOrError(ParseSQLServerDSN(dsn))
+		},
+		TranslateError: translateSQLServerError,
+		// OUTPUT clauses
+		Features: Features{Returning: true, Upsert: UpsertMerge, MaxParams: 2100},
+	})
 }
 
-// Constructs a DSN string from a config object
-func DSNFromConfig(c *config) string {
-	return c.getDSN()
-}
-
-func parse_dsn(dsn string) map[string]string {
-	// Parse DSN and extract connection parameters
-	params := map[string]string{}
-	for _, s := range strings.Split(dsn, " ") {
-		v := strings.Split(s, "=")
-
-		if len(v) == 2 {
-			params[v[0]] = v[1]
-		}
+// Returns c as a Config, or a nil Config if err is set.
+func configOrError[C Config](c C, err error) (Config, error) {
+	if err != nil {
+		return nil, err
 	}
-	return params
+	return c, nil
 }
 
-// ParseDSN parses the DSN string to a config object
-// Default value for sslmode is disable
-// Default value for timezone is UTC
-func ParseDSN(dsn string) (*config, error) {
-	var c config
-
-	if dsn == "" {
-		return nil, fmt.Errorf("cannot parse DSN. %s", "DSN is empty")
-	}
-
-	params := parse_dsn(dsn)
-
-	if sslmode, ok := params["sslmode"]; ok {
-		c.sslmode = sslmode
-	} else {
-		c.sslmode = "disable"
-	}
-
-	if timezone, ok := params["timezone"]; ok {
-		c.timezone = timezone
-	} else {
-		c.timezone = "UTC"
-	}
-
-	if host, ok := params["host"]; ok {
-		c.host = host
-	} else {
-		return nil, fmt.Errorf("cannot parse DSN. %s", "host is empty")
-	}
-
-	if user, ok := params["user"]; ok {
-		c.user = user
-	} else {
-		return nil, fmt.Errorf("cannot parse DSN. %s", "user is empty")
-	}
+// ParseDialect returns the gorm dialector and config of dsn with a
+// registered dialect.
+func ParseDialect(dsn string, dialect DialectString) (gorm.Dialector, gorm.Config, error) {
+	var gormConfig gorm.Config
 
-	if password, ok := params["password"]; ok {
-		c.password = password
-	} else {
-		return nil, fmt.Errorf("cannot parse DSN. %s", "password is empty")
+	factory, ok := LookupDialect(dialect)
+	if !ok {
+		return nil, gormConfig, fmt.Errorf("unknown dialect: %s", dialect)
 	}
 
-	if database, ok := params["dbname"]; ok {
-		c.database = database
-	} else {
-		return nil, fmt.Errorf("cannot parse DSN. %s", "database is empty")
+	if factory.GormConfig != nil {
+		gormConfig = factory.GormConfig()
 	}
 
-	return &c, nil
-}
-
-func ParseDialect(dsn string, dialect DialectString) (gorm.Dialector, gorm.Config, error) {
-	var dialector gorm.Dialector
-	gormConfig := gorm.Config{Logger: logger.Default.LogMode(logger.Info)}
-
 	switch os.Getenv("SQL_LOG_LEVEL") {
 	case "info":
 		gormConfig.Logger = logger.Default.LogMode(logger.Info)
@@ -107,22 +100,5 @@ func ParseDialect(dsn string, dialect DialectString) (gorm.Dialector, gorm.Confi
 		gormConfig.Logger = logger.Default.LogMode(logger.Silent)
 	}
 
-	switch dialect {
-	case PG:
-		dialector = postgres.Open(dsn)
-		gormConfig = gorm.Config{
-			// Cache prepared statements to speedup performance
-			PrepareStmt:                              true,
-			Logger:                                   gormConfig.Logger,
-			DisableForeignKeyConstraintWhenMigrating: false,
-		}
-	case SQLITE3:
-		dialector = sqlite.Open(dsn)
-	case MYSQL:
-		dialector = mysql.Open(dsn)
-	default:
-		return nil, gormConfig, fmt.Errorf("unknown dialect: %s", dialect)
-	}
-
-	return dialector, gormConfig, nil
+	return factory.Open(dsn), gormConfig, nil
 }
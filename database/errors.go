@@ -0,0 +1,103 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Errors of constraint violations, the same for every dialect. The error of
+// the driver stays in the chain, for errors.As.
+var (
+	ErrDuplicateKey        = errors.New("duplicate key")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+)
+
+// Returns err wrapped in kind, unless kind is nil or already wraps err.
+func wrapError(kind error, err error) error {
+	if kind == nil || errors.Is(err, kind) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+func translatePostgresError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	// unique_violation and foreign_key_violation
+	switch pgErr.Code {
+	case "23505":
+		return wrapError(ErrDuplicateKey, err)
+	case "23503":
+		return wrapError(ErrForeignKeyViolation, err)
+	}
+	return err
+}
+
+func translateMySQLError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	// ER_DUP_ENTRY, ER_ROW_IS_REFERENCED_2 and ER_NO_REFERENCED_ROW_2
+	switch mysqlErr.Number {
+	case 1062:
+		return wrapError(ErrDuplicateKey, err)
+	case 1451, 1452:
+		return wrapError(ErrForeignKeyViolation, err)
+	}
+	return err
+}
+
+func translateSQLiteError(err error) error {
+	code, ok := SQLiteErrorCode(err)
+	if !ok {
+		return err
+	}
+
+	switch code {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return wrapError(ErrDuplicateKey, err)
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return wrapError(ErrForeignKeyViolation, err)
+	}
+	return err
+}
+
+func translateSQLServerError(err error) error {
+	var mssqlErr mssql.Error
+	if !errors.As(err, &mssqlErr) {
+		return err
+	}
+
+	switch mssqlErr.Number {
+	case 2601, 2627:
+		return wrapError(ErrDuplicateKey, err)
+	case 547:
+		// 547 also reports CHECK constraints
+		if strings.Contains(mssqlErr.Message, "FOREIGN KEY") || strings.Contains(mssqlErr.Message, "REFERENCE") {
+			return wrapError(ErrForeignKeyViolation, err)
+		}
+	}
+	return err
+}
+
+// SQLiteErrorCode returns the extended result code of an error of either
+// sqlite driver. The primary result code is its lowest byte.
+func SQLiteErrorCode(err error) (int, bool) {
+	var pureErr *gosqlite.Error
+	if errors.As(err, &pureErr) {
+		return pureErr.Code(), true
+	}
+	return cgoSQLiteCode(err)
+}
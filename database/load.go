@@ -0,0 +1,400 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prefix of the environment variables read by LoadConfig.
+var EnvPrefix = "REALORM_"
+
+// Keys read by LoadConfig. In environment variables and .env files they are
+// upper case and prefixed with EnvPrefix, like REALORM_HOST.
+var configKeys = []string{
+	"dialect", "host", "port", "user", "password", "password_file", "database",
+	// postgres
+	"sslmode", "timezone", "application_name", "connect_timeout", "search_path",
+	"sslrootcert", "sslcert", "sslkey", "sslservername",
+	// mysql
+	"net", "charset", "parse_time", "loc", "tls", "tls_ca", "tls_cert", "tls_key", "tls_server_name",
+	// sqlite
+	"path", "journal_mode", "busy_timeout", "foreign_keys", "cache", "mode",
+	// sqlserver
+	"instance", "encrypt", "trust_server_certificate", "certificate", "host_name_in_certificate",
+}
+
+// LoadConfig loads the configuration of a connection from files and the
+// environment, for Connect.
+//
+// files are read in order, each overriding the previous ones, and the
+// environment overrides them all. Files ending in .json hold an object keyed
+// by lower case names, like {"dialect": "postgres", "port": 5432}. Other files
+// are .env files of prefixed variables, like REALORM_DIALECT=postgres. The
+// keys of named databases, see LoadNamedConfig, are skipped. A database is
+// named by a nested object in a JSON file or by its dialect key, like
+// REALORM_ANALYTICS_DIALECT. Other unknown keys, like typos, are errors, in
+// files and in the environment alike.
+//
+// The dialect is required. Postgres, mysql and sqlserver require a user and
+// postgres a database, sqlite3 and sqlite require a path. Postgres defaults to localhost, sslmode
+// disable and timezone UTC. Durations are strings like "5s". If password_file
+// is set, the password is read from it for every new connection.
+func LoadConfig(files ...string) (Config, error) {
+	return loadConfig("", EnvPrefix, files)
+}
+
+// LoadNamedConfig loads the configuration of the connection to the database
+// called name, like LoadConfig, from variables prefixed with EnvPrefix and
+// the upper case name, like REALORM_ANALYTICS_HOST for "analytics". JSON
+// files hold an object per database, like {"analytics": {"dialect": "mysql"}}.
+func LoadNamedConfig(name string, files ...string) (Config, error) {
+	if name == "" {
+		return nil, fmt.Errorf("database name is empty")
+	}
+	return loadConfig(name, EnvPrefix+strings.ToUpper(name)+"_", files)
+}
+
+func loadConfig(name string, prefix string, files []string) (Config, error) {
+	values := map[string]string{}
+	// Databases named in the files and the environment
+	names := map[string]bool{}
+	var unknown []unknownVar
+
+	for _, file := range files {
+		var err error
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			err = loadJSON(file, name, values, names)
+		} else {
+			unknown, err = loadDotEnv(file, prefix, values, unknown)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		if key := strings.ToLower(strings.TrimPrefix(name, prefix)); !oneOf(key, configKeys...) {
+			unknown = append(unknown, unknownVar{name: name, key: key})
+		}
+	}
+
+	for _, v := range unknown {
+		if named, ok := strings.CutSuffix(v.key, "_dialect"); ok {
+			names[named] = true
+		}
+	}
+
+	for _, v := range unknown {
+		if namedKey(v.key, names) {
+			continue
+		}
+
+		if v.file == "" {
+			return nil, fmt.Errorf("unknown environment variable %s", v.name)
+		}
+		return nil, fmt.Errorf("%s:%d: unknown variable %s", v.file, v.line, v.name)
+	}
+
+	for _, key := range configKeys {
+		if value, ok := os.LookupEnv(prefix + strings.ToUpper(key)); ok {
+			values[key] = value
+		}
+	}
+
+	l := &loader{prefix: prefix, values: values}
+	var c Config
+
+	switch dialect := DialectString(strings.ToLower(l.required("dialect"))); dialect {
+	case PG:
+		c = &PostgresConfig{
+			Host:             l.string("host", "localhost"),
+			Port:             l.int("port"),
+			User:             l.required("user"),
+			Password:         l.string("password", ""),
+			PasswordProvider: l.passwordFile(),
+			Database:         l.required("database"),
+			SSLMode:          l.string("sslmode", "disable"),
+			TimeZone:         l.string("timezone", "UTC"),
+			ApplicationName:  l.string("application_name", ""),
+			ConnectTimeout:   l.duration("connect_timeout"),
+			SearchPath:       l.string("search_path", ""),
+			SSLRootCert:      l.string("sslrootcert", ""),
+			SSLCert:          l.string("sslcert", ""),
+			SSLKey:           l.string("sslkey", ""),
+			SSLServerName:    l.string("sslservername", ""),
+		}
+	case MYSQL:
+		c = &MySQLConfig{
+			Host:             l.string("host", ""),
+			Port:             l.int("port"),
+			Net:              l.string("net", ""),
+			User:             l.required("user"),
+			Password:         l.string("password", ""),
+			PasswordProvider: l.passwordFile(),
+			Database:         l.string("database", ""),
+			Charset:          l.string("charset", ""),
+			ParseTime:        l.bool("parse_time"),
+			Loc:              l.string("loc", ""),
+			TLS:              l.string("tls", ""),
+			TLSCAFile:        l.string("tls_ca", ""),
+			TLSCertFile:      l.string("tls_cert", ""),
+			TLSKeyFile:       l.string("tls_key", ""),
+			TLSServerName:    l.string("tls_server_name", ""),
+		}
+	case SQLITE3, SQLITE:
+		c = &SQLiteConfig{
+			Path:        l.required("path"),
+			JournalMode: l.string("journal_mode", ""),
+			BusyTimeout: l.duration("busy_timeout"),
+			ForeignKeys: l.bool("foreign_keys"),
+			Cache:       l.string("cache", ""),
+			Mode:        l.string("mode", ""),
+			PureGo:      dialect == SQLITE,
+		}
+	case SQLSERVER:
+		c = &SQLServerConfig{
+			Host:                   l.string("host", ""),
+			Port:                   l.int("port"),
+			Instance:               l.string("instance", ""),
+			User:                   l.required("user"),
+			Password:               l.string("password", ""),
+			Database:               l.string("database", ""),
+			Encrypt:                l.string("encrypt", ""),
+			TrustServerCertificate: l.bool("trust_server_certificate"),
+			Certificate:            l.string("certificate", ""),
+			HostNameInCertificate:  l.string("host_name_in_certificate", ""),
+			AppName:                l.string("application_name", ""),
+			ConnectTimeout:         l.duration("connect_timeout"),
+			PasswordProvider:       l.passwordFile(),
+		}
+	default:
+		if l.err == nil {
+			l.err = fmt.Errorf("invalid %s: unknown dialect %q", l.envName("dialect"), dialect)
+		}
+	}
+
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reads the keys of a JSON object into values. The keys of the database
+// called name are read from its nested object, if any. Without a name, nested
+// objects hold other databases, which are skipped and added to names.
+func loadJSON(file string, name string, values map[string]string, names map[string]bool) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var object map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&object); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	if name != "" {
+		nested, ok := object[name]
+		if !ok {
+			return nil
+		}
+
+		if object, ok = nested.(map[string]any); !ok {
+			return fmt.Errorf("%s: %s must be an object", file, name)
+		}
+	}
+
+	for key, value := range object {
+		if _, ok := value.(map[string]any); ok && name == "" {
+			names[key] = true
+			continue
+		}
+
+		if !oneOf(key, configKeys...) {
+			return fmt.Errorf("%s: unknown key %q", file, key)
+		}
+
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case json.Number:
+			values[key] = v.String()
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		case nil:
+			delete(values, key)
+		default:
+			return fmt.Errorf("%s: %s must be a string, number or boolean", file, key)
+		}
+	}
+
+	return nil
+}
+
+// A prefixed variable of a .env file or the environment that is not a config
+// key, either the key of a named database or a mistake.
+type unknownVar struct {
+	// Empty for environment variables
+	file string
+	line int
+	name string
+	// Lower case name without the prefix
+	key string
+}
+
+// Reads the variables of a .env file starting with prefix into values.
+// Lines look like REALORM_HOST=localhost, optionally preceded by export and
+// with the value in quotes. Blank lines, comments and other variables are
+// skipped. Prefixed variables that are not config keys, like the variables
+// of named databases, are appended to unknown.
+func loadDotEnv(file string, prefix string, values map[string]string, unknown []unknownVar) ([]unknownVar, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return unknown, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return unknown, fmt.Errorf("%s:%d: missing \"=\"", file, n)
+		}
+
+		name = strings.TrimSpace(name)
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimPrefix(name, prefix))
+		if !oneOf(key, configKeys...) {
+			unknown = append(unknown, unknownVar{file: file, line: n, name: name, key: key})
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+
+	return unknown, scanner.Err()
+}
+
+// Returns true if key is the key of a database in names, like analytics_host.
+func namedKey(key string, names map[string]bool) bool {
+	for _, configKey := range configKeys {
+		name, ok := strings.CutSuffix(key, "_"+configKey)
+		if ok && names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// loader converts configuration values, keeping the first error.
+type loader struct {
+	prefix string
+	values map[string]string
+	err    error
+}
+
+// Returns the environment variable of key.
+func (l *loader) envName(key string) string {
+	return l.prefix + strings.ToUpper(key)
+}
+
+func (l *loader) fail(key string, format string, args ...any) {
+	if l.err == nil {
+		l.err = fmt.Errorf("%s (%s): %s", l.envName(key), key, fmt.Sprintf(format, args...))
+	}
+}
+
+func (l *loader) required(key string) string {
+	value := l.values[key]
+	if value == "" {
+		l.fail(key, "missing required key")
+	}
+	return value
+}
+
+func (l *loader) string(key string, defaultValue string) string {
+	if value := l.values[key]; value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Returns a FilePassword reading password_file, if set.
+func (l *loader) passwordFile() PasswordProvider {
+	if path := l.values["password_file"]; path != "" {
+		return FilePassword(path)
+	}
+	return nil
+}
+
+func (l *loader) int(key string) int {
+	value := l.values[key]
+	if value == "" {
+		return 0
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		l.fail(key, "invalid integer %q", value)
+	}
+	return n
+}
+
+func (l *loader) bool(key string) bool {
+	value := l.values[key]
+	if value == "" {
+		return false
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.fail(key, "invalid boolean %q", value)
+	}
+	return b
+}
+
+func (l *loader) duration(key string) time.Duration {
+	value := l.values[key]
+	if value == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.fail(key, "invalid duration %q", value)
+	}
+	return d
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("error writing %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	jsonFile := writeFile(t, "db.json", `{
+		"dialect": "postgres",
+		"host": "db.example.com",
+		"port": 5432,
+		"user": "json",
+		"database": "realorm",
+		"connect_timeout": "10s"
+	}`)
+
+	envFile := writeFile(t, ".env", `
+# database
+export REALORM_USER=dotenv
+REALORM_PASSWORD="p@ss w=rd"
+OTHER_SERVICE_URL=http://localhost
+`)
+
+	t.Setenv("REALORM_APPLICATION_NAME", "billing")
+	t.Setenv("REALORM_PORT", "5433")
+
+	c, err := LoadConfig(jsonFile, envFile)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	expected := &PostgresConfig{
+		Host:            "db.example.com",
+		Port:            5433,
+		User:            "dotenv",
+		Password:        "p@ss w=rd",
+		Database:        "realorm",
+		SSLMode:         "disable",
+		TimeZone:        "UTC",
+		ApplicationName: "billing",
+		ConnectTimeout:  10 * time.Second,
+	}
+
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+}
+
+func TestLoadConfigSQLite(t *testing.T) {
+	t.Setenv("REALORM_DIALECT", string(SQLITE_DEFAULT))
+	t.Setenv("REALORM_PATH", filepath.Join(t.TempDir(), "app.db"))
+	t.Setenv("REALORM_BUSY_TIMEOUT", "5s")
+	t.Setenv("REALORM_FOREIGN_KEYS", "true")
+
+	c, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	if c.Dialect() != SQLITE_DEFAULT || !c.(*SQLiteConfig).ForeignKeys || c.(*SQLiteConfig).BusyTimeout != 5*time.Second {
+		t.Errorf("unexpected config %+v", c)
+	}
+
+	if _, err := Connect(c, c.Dialect()); err != nil {
+		t.Errorf("error connecting: %v", err)
+	}
+}
+
+func TestLoadNamedConfig(t *testing.T) {
+	env := writeFile(t, ".env", "REALORM_ANALYTICS_DIALECT=mysql\nREALORM_ANALYTICS_USER=analyst\nREALORM_HOST=ignored\n")
+	t.Setenv("REALORM_ANALYTICS_DATABASE", "events")
+
+	c, err := LoadNamedConfig("analytics", env)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	expected := &MySQLConfig{User: "analyst", Database: "events"}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+
+	_, err = LoadNamedConfig("legacy")
+	if err == nil || !strings.Contains(err.Error(), "REALORM_LEGACY_DIALECT (dialect): missing required key") {
+		t.Errorf("expected error naming REALORM_LEGACY_DIALECT, got %v", err)
+	}
+}
+
+func TestLoadNamedConfigShared(t *testing.T) {
+	jsonFile := writeFile(t, "db.json", `{
+		"dialect": "postgres",
+		"user": "main",
+		"database": "realorm",
+		"analytics": {"dialect": "mysql", "user": "analyst"},
+		"legacy": {"dialect": "sqlserver", "user": "sa"}
+	}`)
+
+	envFile := writeFile(t, ".env", "REALORM_HOST=db\nREALORM_ANALYTICS_DATABASE=events\nREALORM_LEGACY_HOST=legacy\n")
+
+	main, err := LoadConfig(jsonFile, envFile)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	expected := &PostgresConfig{Host: "db", User: "main", Database: "realorm", SSLMode: "disable", TimeZone: "UTC"}
+	if !reflect.DeepEqual(main, expected) {
+		t.Errorf("expected %+v, got %+v", expected, main)
+	}
+
+	analytics, err := LoadNamedConfig("analytics", jsonFile, envFile)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	if !reflect.DeepEqual(analytics, &MySQLConfig{User: "analyst", Database: "events"}) {
+		t.Errorf("unexpected analytics config %+v", analytics)
+	}
+
+	// a database named only by its dialect variable
+	envFile = writeFile(t, ".env", "REALORM_DIALECT=sqlite3\nREALORM_PATH=app.db\nREALORM_CACHE_DIALECT=sqlite3\nREALORM_CACHE_PATH=cache.db\n")
+	if _, err := LoadConfig(envFile); err != nil {
+		t.Errorf("expected the keys of the cache database to be skipped, got %v", err)
+	}
+
+	invalid := writeFile(t, "db.json", `{"analytics": "mysql"}`)
+	_, err = LoadNamedConfig("analytics", invalid)
+	if err == nil || !strings.Contains(err.Error(), "analytics must be an object") {
+		t.Errorf("expected error for a non-object database, got %v", err)
+	}
+}
+
+func TestLoadConfigTypos(t *testing.T) {
+	for _, typo := range []string{"REALORM_DATABSE_HOST=db", "REALORM_HSOT_PORT=5432"} {
+		env := writeFile(t, ".env", "REALORM_DIALECT=postgres\nREALORM_USER=realorm\nREALORM_DATABASE=realorm\n"+typo+"\n")
+		name, _, _ := strings.Cut(typo, "=")
+
+		_, err := LoadConfig(env)
+		if err == nil || !strings.Contains(err.Error(), "unknown variable "+name) {
+			t.Errorf("expected error naming %s, got %v", name, err)
+		}
+
+		_, err = LoadNamedConfig("analytics", env, writeFile(t, ".env", "REALORM_ANALYTICS_HSOT=db\n"))
+		if err == nil || !strings.Contains(err.Error(), "unknown variable REALORM_ANALYTICS_HSOT") {
+			t.Errorf("expected error naming REALORM_ANALYTICS_HSOT, got %v", err)
+		}
+	}
+
+	// typos in the environment
+	env := writeFile(t, ".env", "REALORM_DIALECT=postgres\nREALORM_USER=realorm\nREALORM_DATABASE=realorm\n")
+	t.Setenv("REALORM_HOTS", "db")
+
+	_, err := LoadConfig(env)
+	if err == nil || !strings.Contains(err.Error(), "unknown environment variable REALORM_HOTS") {
+		t.Errorf("expected error naming REALORM_HOTS, got %v", err)
+	}
+
+	// unless they belong to a named database
+	os.Unsetenv("REALORM_HOTS")
+	t.Setenv("REALORM_BILLING_DIALECT", "mysql")
+	t.Setenv("REALORM_BILLING_HOST", "db")
+
+	if _, err := LoadConfig(env); err != nil {
+		t.Errorf("error loading config beside a named database: %v", err)
+	}
+
+	t.Setenv("REALORM_BILLING_HOTS", "db")
+	if _, err := LoadNamedConfig("billing", env); err == nil || !strings.Contains(err.Error(), "REALORM_BILLING_HOTS") {
+		t.Errorf("expected error naming REALORM_BILLING_HOTS, got %v", err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		file     string
+		expected string
+	}{
+		{env: map[string]string{}, expected: "REALORM_DIALECT (dialect): missing required key"},
+		{env: map[string]string{"REALORM_DIALECT": "postgres", "REALORM_DATABASE": "realorm"}, expected: "REALORM_USER (user): missing required key"},
+		{env: map[string]string{"REALORM_DIALECT": "oracle"}, expected: `unknown dialect "oracle"`},
+		{env: map[string]string{"REALORM_DIALECT": "mysql", "REALORM_USER": "realorm", "REALORM_PORT": "abc"}, expected: "REALORM_PORT (port): invalid integer"},
+		{env: map[string]string{"REALORM_DIALECT": "sqlite3", "REALORM_PATH": "app.db", "REALORM_JOURNAL_MODE": "fast"}, expected: "unknown journal_mode"},
+		{file: `{"dialect": "sqlite3", "pth": "app.db"}`, expected: `unknown key "pth"`},
+		{file: `{"dialect": "sqlite3", "analytics": "app.db"}`, expected: `unknown key "analytics"`},
+	}
+
+	for _, test := range tests {
+		for _, key := range configKeys {
+			t.Setenv(EnvPrefix+strings.ToUpper(key), "")
+			os.Unsetenv(EnvPrefix + strings.ToUpper(key))
+		}
+
+		for key, value := range test.env {
+			t.Setenv(key, value)
+		}
+
+		var files []string
+		if test.file != "" {
+			files = append(files, writeFile(t, "db.json", test.file))
+		}
+
+		_, err := LoadConfig(files...)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected error containing %q, got %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SlogConfig configures a SlogLogger.
+type SlogConfig struct {
+	// Queries slower than SlowThreshold are logged at warn level.
+	// Zero disables slow query detection.
+	SlowThreshold time.Duration
+	// Level of the logger. Defaults to logger.Warn.
+	LogLevel logger.LogLevel
+	// Do not log gorm.ErrRecordNotFound errors
+	IgnoreRecordNotFoundError bool
+	// Log SQL with the bound values. By default, values are replaced with ?
+	// so passwords and personal data never reach the logs.
+	LogValues bool
+}
+
+// SlogLogger is a gorm logger writing structured records with log/slog.
+type SlogLogger struct {
+	logger  *slog.Logger
+	dialect DialectString
+	config  SlogConfig
+}
+
+// NewSlogLogger returns a gorm logger writing to l.
+// dialect is the dialect of the logged queries, used to redact their values.
+func NewSlogLogger(l *slog.Logger, dialect DialectString, config SlogConfig) *SlogLogger {
+	if config.LogLevel == 0 {
+		config.LogLevel = logger.Warn
+	}
+
+	return &SlogLogger{logger: l, dialect: dialect, config: config}
+}
+
+// LogMode returns a copy of the logger with level.
+func (l *SlogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	c := *l
+	c.config.LogLevel = level
+	return &c
+}
+
+func (l *SlogLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Info {
+		l.logger.InfoContext(ctx, fmt.Sprintf(msg, data...), slog.String("caller", caller()))
+	}
+}
+
+func (l *SlogLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Warn {
+		l.logger.WarnContext(ctx, fmt.Sprintf(msg, data...), slog.String("caller", caller()))
+	}
+}
+
+func (l *SlogLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Error {
+		l.logger.ErrorContext(ctx, fmt.Sprintf(msg, data...), slog.String("caller", caller()))
+	}
+}
+
+// Trace logs a query. Failed queries are logged at error level, slow
+// queries at warn level and all others at info level.
+func (l *SlogLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.config.LogLevel <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	failed := err != nil && !(l.config.IgnoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound))
+	slow := l.config.SlowThreshold > 0 && elapsed > l.config.SlowThreshold
+
+	switch {
+	case failed && l.config.LogLevel >= logger.Error:
+		l.logger.ErrorContext(ctx, "query failed", append(l.attrs(fc, elapsed), slog.String("error", err.Error()))...)
+	case slow && l.config.LogLevel >= logger.Warn:
+		l.logger.WarnContext(ctx, "slow query", append(l.attrs(fc, elapsed), slog.Duration("threshold", l.config.SlowThreshold))...)
+	case l.config.LogLevel >= logger.Info:
+		l.logger.InfoContext(ctx, "query", l.attrs(fc, elapsed)...)
+	}
+}
+
+// Returns the attributes of a query record.
+func (l *SlogLogger) attrs(fc func() (string, int64), elapsed time.Duration) []any {
+	sql, rows := fc()
+
+	// the arguments are counted whether or not their values are logged
+	redacted, args := RedactSQL(sql, l.dialect)
+	if !l.config.LogValues {
+		sql = redacted
+	}
+
+	return []any{
+		slog.String("sql", sql),
+		slog.Int("args", args),
+		slog.Duration("duration", elapsed),
+		slog.Int64("rows", rows),
+		slog.String("caller", caller()),
+	}
+}
+
+// RedactSQL replaces the string and numeric literals of sql, as rendered by
+// gorm for dialect, with ? and returns the number of literals replaced.
+func RedactSQL(sql string, dialect DialectString) (string, int) {
+	// quote of values; identifiers are quoted with backticks or double quotes
+	quote := byte('\'')
+	if dialect == SQLITE3 || dialect == SQLITE {
+		quote = '"'
+	}
+
+	var b strings.Builder
+	var count int
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+
+		switch {
+		case c == quote:
+			// skip to the closing quote; gorm escapes quotes with a backslash
+			j := i + 1
+			for j < len(sql) && sql[j] != quote {
+				if sql[j] == '\\' {
+					j++
+				}
+				j++
+			}
+
+			b.WriteByte('?')
+			count++
+			i = j
+		case c == '`' || c == '"':
+			// quoted identifier
+			j := strings.IndexByte(sql[i+1:], c)
+			if j < 0 {
+				b.WriteString(sql[i:])
+				return b.String(), count
+			}
+
+			b.WriteString(sql[i : i+j+2])
+			i += j + 1
+		case isDigit(c) && (i == 0 || !isIdentifier(sql[i-1])):
+			j := i
+			for j < len(sql) && (isDigit(sql[j]) || sql[j] == '.') {
+				j++
+			}
+
+			b.WriteByte('?')
+			count++
+			i = j - 1
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			// postgres placeholder
+			j := i + 1
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+
+			b.WriteString(sql[i:j])
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), count
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentifier(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Returns the file and line of the first caller outside gorm and realorm.
+func caller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	for {
+		frame, more := frames.Next()
+		internal := strings.Contains(frame.Function, "gorm.io/") ||
+			(strings.Contains(frame.Function, "abiiranathan/realorm/") && !strings.HasSuffix(frame.File, "_test.go"))
+
+		if !internal {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
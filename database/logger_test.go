@@ -0,0 +1,127 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRedactSQL(t *testing.T) {
+	tests := []struct {
+		sql      string
+		dialect  DialectString
+		expected string
+		args     int
+	}{
+		{
+			sql:      `SELECT * FROM "users" WHERE email = 'john@example.com' AND age > 30 LIMIT 1`,
+			dialect:  PG,
+			expected: `SELECT * FROM "users" WHERE email = ? AND age > ? LIMIT ?`,
+			args:     3,
+		},
+		{
+			sql:      "INSERT INTO `users` (`name`,`password`,`col2`) VALUES (\"jo\\\"hn\",\"s3cret\",2.5)",
+			dialect:  SQLITE3,
+			expected: "INSERT INTO `users` (`name`,`password`,`col2`) VALUES (?,?,?)",
+			args:     3,
+		},
+		{
+			sql:      "UPDATE `users` SET `password`='it\\'s' WHERE `id` = 7",
+			dialect:  MYSQL,
+			expected: "UPDATE `users` SET `password`=? WHERE `id` = ?",
+			args:     2,
+		},
+		{
+			sql:      `SELECT * FROM users WHERE id = $1`,
+			dialect:  PG,
+			expected: `SELECT * FROM users WHERE id = $1`,
+			args:     0,
+		},
+	}
+
+	for _, test := range tests {
+		sql, args := RedactSQL(test.sql, test.dialect)
+
+		if sql != test.expected {
+			t.Errorf("expected %s, got %s\n", test.expected, sql)
+		}
+
+		if args != test.args {
+			t.Errorf("expected %d args, got %d\n", test.args, args)
+		}
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)), SQLITE_DEFAULT, SlogConfig{
+		SlowThreshold: time.Millisecond,
+		LogLevel:      logger.Info,
+	})
+
+	db, err := Connect(SQLITE3_MEMORY_DB, SQLITE_DEFAULT)
+	if err != nil {
+		t.Fatalf("error creating database: %v\n", err)
+	}
+
+	db = db.Session(&gorm.Session{Logger: l})
+	db.AutoMigrate(&Entity{})
+	buf.Reset()
+
+	db.Where("id = ?", "secret-value").Find(&[]Entity{})
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("error decoding record %s: %v\n", buf.String(), err)
+	}
+
+	if strings.Contains(buf.String(), "secret-value") {
+		t.Errorf("expected the value to be redacted: %s\n", buf.String())
+	}
+
+	if record["msg"] != "query" || record["args"] != float64(1) || record["rows"] != float64(0) {
+		t.Errorf("unexpected record: %v\n", record)
+	}
+
+	if !strings.Contains(record["caller"].(string), "logger_test.go") {
+		t.Errorf("expected caller in logger_test.go, got %v\n", record["caller"])
+	}
+
+	// slow queries are logged at warn level
+	buf.Reset()
+	l.Trace(context.Background(), time.Now().Add(-time.Second), func() (string, int64) { return "SELECT 1", 1 }, nil)
+	if !strings.Contains(buf.String(), `"level":"WARN","msg":"slow query"`) {
+		t.Errorf("expected slow query warning, got %s\n", buf.String())
+	}
+
+	// arguments are counted when their values are logged too
+	buf.Reset()
+	values := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)), SQLITE_DEFAULT, SlogConfig{
+		LogLevel:  logger.Info,
+		LogValues: true,
+	})
+
+	db.Session(&gorm.Session{Logger: values}).Where("id IN ?", []string{"a", "b"}).Find(&[]Entity{})
+	record = nil
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("error decoding record %s: %v\n", buf.String(), err)
+	}
+
+	if record["args"] != float64(2) || !strings.Contains(record["sql"].(string), `("a","b")`) {
+		t.Errorf("expected 2 logged args, got %v\n", record)
+	}
+
+	// silent loggers log nothing
+	buf.Reset()
+	l.LogMode(logger.Silent).Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 1", 1 }, gorm.ErrRecordNotFound)
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %s\n", buf.String())
+	}
+}
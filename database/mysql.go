@@ -0,0 +1,195 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQLConfig holds the settings of a mysql connection.
+type MySQLConfig struct {
+	Host     string // Defaults to 127.0.0.1, or the socket path if Net is unix
+	Port     int    // 0 means the default port
+	Net      string // tcp or unix. Defaults to tcp
+	User     string
+	Password string
+	Database string
+	// Asked for the password of every new connection instead of Password
+	PasswordProvider PasswordProvider
+
+	// Character set of the connection. Defaults to utf8mb4
+	Charset string
+	// Scan DATE and DATETIME values into time.Time
+	ParseTime bool
+	// Location of time.Time values, like "UTC", "Local" or "Africa/Kampala".
+	// Defaults to UTC
+	Loc string
+	// TLS mode: "true", "false", "skip-verify", "preferred", "verify-full",
+	// "verify-ca" or the name of a registered tls.Config. With TLS files or a
+	// server name, it is "verify-full" (the default), "verify-ca" or
+	// "skip-verify". "verify-full" and "verify-ca" register a tls.Config
+	// with RegisterTLS, trusting the system roots without a CA file.
+	TLS string
+	// CA certificate, client certificate and client key files
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+	// Host name expected in the server certificate. Defaults to Host
+	TLSServerName string
+
+	// Dial, read and write timeouts
+	Timeout      time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// Other parameters passed to the driver as is
+	Params map[string]string
+}
+
+func (c *MySQLConfig) Dialect() DialectString {
+	return MYSQL
+}
+
+// Validate returns an error naming the first invalid setting of c.
+func (c *MySQLConfig) Validate() error {
+	switch {
+	case c.User == "":
+		return fmt.Errorf("invalid mysql config: user is empty")
+	case c.Net != "" && c.Net != "tcp" && c.Net != "unix":
+		return fmt.Errorf("invalid mysql config: unknown net %q", c.Net)
+	case c.Net == "unix" && c.Host == "":
+		return fmt.Errorf("invalid mysql config: socket path is empty")
+	case c.Port < 0 || c.Port > 65535:
+		return fmt.Errorf("invalid mysql config: invalid port %d", c.Port)
+	case c.Password != "" && c.PasswordProvider != nil:
+		return fmt.Errorf("invalid mysql config: password and password provider are both set")
+	}
+
+	if _, err := c.location(); err != nil {
+		return fmt.Errorf("invalid mysql config: unknown loc %q", c.Loc)
+	}
+
+	if c.hasCustomTLS() {
+		if !oneOf(c.TLS, "", "true", "verify-full", "verify-ca", "skip-verify") {
+			return fmt.Errorf("invalid mysql config: tls %q cannot be used with tls files", c.TLS)
+		}
+
+		if err := checkTLSFiles(c.TLSCAFile, c.TLSCertFile, c.TLSKeyFile); err != nil {
+			return fmt.Errorf("invalid mysql config: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *MySQLConfig) location() (*time.Location, error) {
+	if c.Loc == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(c.Loc)
+}
+
+// DSN returns c as a go-sql-driver/mysql connection string, like
+// "user:password@tcp(127.0.0.1:3306)/realorm?charset=utf8mb4".
+func (c *MySQLConfig) DSN() string {
+	cfg := mysql.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Password
+	cfg.DBName = c.Database
+	cfg.ParseTime = c.ParseTime
+	cfg.TLSConfig = c.TLS
+	if c.hasCustomTLS() {
+		cfg.TLSConfig = c.tlsName()
+	}
+	cfg.Timeout = c.Timeout
+	cfg.ReadTimeout = c.ReadTimeout
+	cfg.WriteTimeout = c.WriteTimeout
+
+	cfg.Net = c.Net
+	if cfg.Net == "" {
+		cfg.Net = "tcp"
+	}
+
+	cfg.Addr = c.Host
+	if cfg.Net == "tcp" {
+		host, port := c.Host, c.Port
+		if host == "" {
+			host = "127.0.0.1"
+		}
+
+		if port == 0 {
+			port = 3306
+		}
+		cfg.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+
+	if loc, err := c.location(); err == nil {
+		cfg.Loc = loc
+	}
+
+	cfg.Params = map[string]string{}
+	for key, value := range c.Params {
+		cfg.Params[key] = value
+	}
+
+	cfg.Params["charset"] = c.Charset
+	if c.Charset == "" {
+		cfg.Params["charset"] = "utf8mb4"
+	}
+
+	return cfg.FormatDSN()
+}
+
+// ParseMySQLDSN parses a go-sql-driver/mysql connection string, like
+// "user:password@tcp(127.0.0.1:3306)/realorm?parseTime=true".
+func ParseMySQLDSN(dsn string) (*MySQLConfig, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse DSN. %w", err)
+	}
+
+	c := &MySQLConfig{
+		Net:          cfg.Net,
+		User:         cfg.User,
+		Password:     cfg.Passwd,
+		Database:     cfg.DBName,
+		ParseTime:    cfg.ParseTime,
+		TLS:          cfg.TLSConfig,
+		Timeout:      cfg.Timeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	if cfg.Loc != nil && cfg.Loc != time.UTC {
+		c.Loc = cfg.Loc.String()
+	}
+
+	c.Host = cfg.Addr
+	if cfg.Net == "tcp" {
+		host, port, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse DSN. invalid address %q", cfg.Addr)
+		}
+
+		c.Host = host
+		if c.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("cannot parse DSN. invalid port %q", port)
+		}
+	}
+
+	for key, value := range cfg.Params {
+		if key == "charset" {
+			c.Charset = value
+			continue
+		}
+
+		if c.Params == nil {
+			c.Params = map[string]string{}
+		}
+		c.Params[key] = value
+	}
+
+	return c, nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestMySQLConfig(t *testing.T) {
+	c := &MySQLConfig{
+		Host:      "db.example.com",
+		User:      "realorm",
+		Password:  "p@ss:w/rd",
+		Database:  "realorm",
+		ParseTime: true,
+		Loc:       "Africa/Kampala",
+		TLS:       "skip-verify",
+		Timeout:   5 * time.Second,
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("error validating config: %v", err)
+	}
+
+	dsn := c.DSN()
+	expected := "realorm:p@ss:w/rd@tcp(db.example.com:3306)/realorm?loc=Africa%2FKampala&parseTime=true&timeout=5s&tls=skip-verify&charset=utf8mb4"
+	if dsn != expected {
+		t.Errorf("expected %s, got %s", expected, dsn)
+	}
+
+	parsed, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("error parsing dsn: %v", err)
+	}
+
+	if parsed.Passwd != c.Password || parsed.Addr != "db.example.com:3306" || parsed.Loc.String() != "Africa/Kampala" {
+		t.Errorf("unexpected parsed config: %+v", parsed)
+	}
+
+	invalid := []*MySQLConfig{
+		{},
+		{User: "realorm", Loc: "Nowhere/Atlantis"},
+		{User: "realorm", Net: "udp"},
+		{User: "realorm", Net: "unix"},
+		{User: "realorm", Port: 70000},
+	}
+
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected error validating %+v", c)
+		}
+	}
+
+	if _, err := Connect(c, PG); err == nil {
+		t.Errorf("expected error connecting to postgres with a mysql config")
+	}
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/denisenkom/go-mssqldb/msdsn"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Replaces passwords in redacted DSNs and errors.
+const redactedPassword = "xxxxx"
+
+// Redacted, String and GoString of the configs have value receivers, unlike
+// their other methods, so that configs printed by value, or held by value in
+// other structs, are redacted as well as pointers to them.
+
+// Redacted returns the DSN of c with its password masked, for logs.
+func (c PostgresConfig) Redacted() string {
+	if c.Password != "" {
+		c.Password = redactedPassword
+	}
+
+	c.Params = redactParams(c.Params)
+	return c.DSN()
+}
+
+// String returns the redacted DSN of c, so printing c never shows its
+// password.
+func (c PostgresConfig) String() string {
+	return c.Redacted()
+}
+
+func (c PostgresConfig) GoString() string {
+	return fmt.Sprintf("database.PostgresConfig{%s}", c.Redacted())
+}
+
+// Redacted returns the DSN of c with its password masked, for logs.
+func (c MySQLConfig) Redacted() string {
+	if c.Password != "" {
+		c.Password = redactedPassword
+	}
+
+	c.Params = redactParams(c.Params)
+	return c.DSN()
+}
+
+// String returns the redacted DSN of c, so printing c never shows its
+// password.
+func (c MySQLConfig) String() string {
+	return c.Redacted()
+}
+
+func (c MySQLConfig) GoString() string {
+	return fmt.Sprintf("database.MySQLConfig{%s}", c.Redacted())
+}
+
+// Redacted returns the DSN of c with passwords in its parameters masked.
+func (c SQLiteConfig) Redacted() string {
+	c.Params = redactParams(c.Params)
+	return c.DSN()
+}
+
+// String returns the redacted DSN of c.
+func (c SQLiteConfig) String() string {
+	return c.Redacted()
+}
+
+func (c SQLiteConfig) GoString() string {
+	return fmt.Sprintf("database.SQLiteConfig{%s}", c.Redacted())
+}
+
+// Redacted returns the DSN of c with its password masked, for logs.
+func (c SQLServerConfig) Redacted() string {
+	if c.Password != "" {
+		c.Password = redactedPassword
+	}
+
+	c.Params = redactParams(c.Params)
+	return c.DSN()
+}
+
+// String returns the redacted DSN of c, so printing c never shows its
+// password.
+func (c SQLServerConfig) String() string {
+	return c.Redacted()
+}
+
+func (c SQLServerConfig) GoString() string {
+	return fmt.Sprintf("database.SQLServerConfig{%s}", c.Redacted())
+}
+
+// Returns true if the parameter key holds a password, like _auth_pass.
+func isPasswordKey(key string) bool {
+	return strings.Contains(strings.ToLower(key), "pass")
+}
+
+// Returns a copy of params with passwords masked.
+func redactParams(params map[string]string) map[string]string {
+	if params == nil {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(params))
+	for key, value := range params {
+		if isPasswordKey(key) && value != "" {
+			value = redactedPassword
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+var (
+	keywordPassword = regexp.MustCompile(`(?i)((?:^|\s)password\s*=\s*)('(?:\\.|[^'\\])*'?|\S*)`)
+	queryPassword   = regexp.MustCompile(`(?i)([?&][^=&]*pass[^=&]*=)[^&]*`)
+	// server=host;user id=sa;password=s3cret;database=realorm
+	adoPassword = regexp.MustCompile(`(?i)((?:^|;)\s*(?:password|pwd)\s*=\s*)("(?:""|[^"])*"?|\{(?:\}\}|[^}])*\}?|[^;]*)`)
+)
+
+// RedactDSN returns dsn with its passwords masked. It understands postgres
+// keyword/value strings, URLs, mysql DSNs like user:password@tcp(host)/db
+// and sqlserver strings like server=host;user id=sa;password=s3cret.
+func RedactDSN(dsn string) string {
+	if !strings.Contains(dsn, "://") && strings.Contains(dsn, ";") {
+		return adoPassword.ReplaceAllString(dsn, "${1}"+redactedPassword)
+	}
+
+	if strings.Contains(dsn, "://") {
+		if u, err := url.Parse(dsn); err == nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redactedPassword)
+			}
+			dsn = u.String()
+		} else if at := strings.LastIndex(dsn, "@"); at >= 0 {
+			// mask the whole user info of URLs that cannot be parsed
+			scheme := strings.Index(dsn, "://") + 3
+			if scheme < at {
+				dsn = dsn[:scheme] + redactedPassword + dsn[at:]
+			}
+		}
+	} else if slash := strings.LastIndex(dsn, "/"); slash > 0 {
+		// user:password@net(addr)/dbname
+		if at := strings.LastIndex(dsn[:slash], "@"); at > 0 {
+			if colon := strings.Index(dsn[:at], ":"); colon >= 0 {
+				dsn = dsn[:colon+1] + redactedPassword + dsn[at:]
+			}
+		}
+	}
+
+	dsn = keywordPassword.ReplaceAllString(dsn, "${1}"+redactedPassword)
+	return queryPassword.ReplaceAllString(dsn, "${1}"+redactedPassword)
+}
+
+// Returns the passwords held by dsn.
+func dsnPasswords(dsn string) []string {
+	var passwords []string
+
+	var params map[string]string
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		params, _ = parseURL(dsn)
+	} else {
+		params, _ = parseKeywordValue(dsn)
+	}
+
+	for key, value := range params {
+		if isPasswordKey(key) {
+			passwords = append(passwords, value)
+		}
+	}
+
+	if cfg, err := mysql.ParseDSN(dsn); err == nil {
+		passwords = append(passwords, cfg.Passwd)
+	}
+
+	if strings.Contains(dsn, ";") {
+		if cfg, _, err := msdsn.Parse(dsn); err == nil {
+			passwords = append(passwords, cfg.Password)
+		}
+	}
+
+	if u, err := url.Parse(dsn); err == nil {
+		if password, ok := u.User.Password(); ok {
+			passwords = append(passwords, password)
+		}
+
+		for key, values := range u.Query() {
+			if isPasswordKey(key) {
+				passwords = append(passwords, values...)
+			}
+		}
+	}
+
+	return passwords
+}
+
+// redactedError is an error whose message had passwords masked.
+// Unwrap returns the original error, for errors.Is and errors.As.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Returns err with dsn and passwords masked in its message.
+func redactError(err error, dsn string, passwords ...string) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	if dsn != "" {
+		msg = strings.ReplaceAll(msg, dsn, RedactDSN(dsn))
+		passwords = append(passwords, dsnPasswords(dsn)...)
+	}
+
+	for _, password := range passwords {
+		if password == "" || password == redactedPassword {
+			continue
+		}
+
+		for _, form := range []string{password, url.QueryEscape(password), url.PathEscape(password), quoteValue(password)} {
+			msg = strings.ReplaceAll(msg, form, redactedPassword)
+		}
+	}
+
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
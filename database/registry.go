@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// UpsertSyntax is the statement a dialect inserts or updates rows with.
+type UpsertSyntax string
+
+const (
+	UpsertNone           UpsertSyntax = ""
+	UpsertOnConflict     UpsertSyntax = "ON CONFLICT"
+	UpsertOnDuplicateKey UpsertSyntax = "ON DUPLICATE KEY UPDATE"
+	UpsertMerge          UpsertSyntax = "MERGE"
+)
+
+// Features of the SQL of a dialect.
+type Features struct {
+	// Inserts, updates and deletes return rows, with RETURNING or OUTPUT
+	Returning bool
+	// Syntax of upserts
+	Upsert UpsertSyntax
+	// Maximum number of bind parameters of a statement. Zero means no limit
+	MaxParams int
+}
+
+// DialectFactory creates the connections of a dialect.
+type DialectFactory struct {
+	// Returns the gorm dialector of a DSN. Required
+	Open func(dsn string) gorm.Dialector
+	// Returns the default gorm config of connections, whose logger
+	// ParseDialect replaces. Defaults to an empty config
+	GormConfig func() gorm.Config
+	// Parses a DSN into a config whose DSN method builds it back
+	ParseDSN func(dsn string) (Config, error)
+	// Wraps the constraint violations of the driver in ErrDuplicateKey or
+	// ErrForeignKeyViolation and returns other errors as is
+	TranslateError func(err error) error
+	Features       Features
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[DialectString]*DialectFactory{}
+)
+
+// RegisterDialect makes a dialect available to ParseDialect and Connect by
+// name. Like sql.Register, it panics if factory has no Open function or if
+// it is called twice for the same name.
+func RegisterDialect(name DialectString, factory DialectFactory) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+
+	if factory.Open == nil {
+		panic(fmt.Sprintf("database: dialect %s has no Open function", name))
+	}
+
+	if _, dup := dialects[name]; dup {
+		panic(fmt.Sprintf("database: RegisterDialect called twice for dialect %s", name))
+	}
+	dialects[name] = &factory
+}
+
+// Removes the dialect registered as name, for tests.
+func unregisterDialect(name DialectString) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+
+	delete(dialects, name)
+}
+
+// LookupDialect returns the factory registered for name.
+func LookupDialect(name DialectString) (*DialectFactory, bool) {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+
+	factory, ok := dialects[name]
+	return factory, ok
+}
+
+// Dialects returns the names of the registered dialects, sorted.
+func Dialects() []DialectString {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+
+	names := make([]DialectString, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// FeaturesOf returns the features of a registered dialect.
+func FeaturesOf(name DialectString) (Features, bool) {
+	factory, ok := LookupDialect(name)
+	if !ok {
+		return Features{}, false
+	}
+	return factory.Features, true
+}
+
+// TranslateError translates err with the error translator of dialect.
+// It returns err as is if the dialect is unknown or has no translator.
+func TranslateError(dialect DialectString, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	factory, ok := LookupDialect(dialect)
+	if !ok || factory.TranslateError == nil {
+		return err
+	}
+	return factory.TranslateError(err)
+}
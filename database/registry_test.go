@@ -0,0 +1,102 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRegisterDialect(t *testing.T) {
+	for _, name := range []DialectString{PG, MYSQL, SQLITE_DEFAULT, SQLITE, SQLSERVER} {
+		if _, ok := LookupDialect(name); !ok {
+			t.Errorf("expected built-in dialect %s to be registered", name)
+		}
+	}
+
+	// the cgo driver is only linked in builds with cgo
+	if _, ok := LookupDialect(SQLITE3); ok != (SQLITE_DEFAULT == SQLITE3) {
+		t.Errorf("expected sqlite3 to be registered only with cgo, got %v", ok)
+	}
+
+	translated := errors.New("translated")
+	t.Cleanup(func() { unregisterDialect("memsql") })
+	RegisterDialect("memsql", DialectFactory{
+		Open: func(dsn string) gorm.Dialector {
+			return sqlite.Open(dsn)
+		},
+		GormConfig:     func() gorm.Config { return gorm.Config{SkipDefaultTransaction: true} },
+		TranslateError: func(err error) error { return translated },
+		Features:       Features{Upsert: UpsertOnConflict, MaxParams: 999},
+	})
+
+	db, err := Connect(SQLITE3_MEMORY_DB, "memsql")
+	if err != nil {
+		t.Fatalf("error connecting with a registered dialect: %v", err)
+	}
+
+	if !db.Config.SkipDefaultTransaction || db.Config.Logger == nil {
+		t.Errorf("expected the gorm config of the dialect with a logger")
+	}
+
+	if features, _ := FeaturesOf("memsql"); features.MaxParams != 999 {
+		t.Errorf("unexpected features %+v", features)
+	}
+
+	if err := TranslateError("memsql", errors.New("driver error")); err != translated {
+		t.Errorf("expected the translator of the dialect, got %v", err)
+	}
+
+	if _, err := Connect(SQLITE3_MEMORY_DB, "nosql"); err == nil {
+		t.Errorf("expected error connecting with an unknown dialect")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected registering memsql twice to panic")
+			}
+		}()
+		RegisterDialect("memsql", DialectFactory{Open: sqlite.Open})
+	}()
+}
+
+func TestDialectParseDSN(t *testing.T) {
+	configs := []Config{
+		&PostgresConfig{Host: "localhost", Port: 5433, User: "realorm", Password: "s3 cret", Database: "realorm", SSLMode: "disable", TimeZone: "UTC"},
+		&MySQLConfig{Host: "db", Port: 3306, Net: "tcp", User: "realorm", Password: "p@ss", Database: "realorm", Charset: "utf8mb4", ParseTime: true, Loc: "Africa/Kampala", Timeout: 5 * time.Second},
+		&SQLiteConfig{Path: "app?.db", JournalMode: "WAL", BusyTimeout: 5 * time.Second, ForeignKeys: true, Cache: "shared"},
+		&SQLiteConfig{Path: "app.db", JournalMode: "WAL", BusyTimeout: time.Second, ForeignKeys: true, PureGo: true},
+		&SQLServerConfig{Host: "db", Port: 1434, Instance: "SQLEXPRESS", User: "sa", Password: "s3cret", Database: "realorm", Encrypt: "true", AppName: "billing", ConnectTimeout: 2 * time.Second},
+	}
+
+	for _, c := range configs {
+		factory, ok := LookupDialect(c.Dialect())
+		if !ok {
+			// sqlite3 in builds without cgo
+			continue
+		}
+
+		parsed, err := factory.ParseDSN(c.DSN())
+		if err != nil {
+			t.Errorf("%s: error parsing %v: %v", c.Dialect(), c, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(parsed, c) {
+			t.Errorf("%s: expected %#v, got %#v", c.Dialect(), c, parsed)
+		}
+	}
+
+	ado, err := ParseSQLServerDSN("server=db\\SQLEXPRESS;user id=sa;password=s3cret;database=realorm")
+	if err != nil {
+		t.Fatalf("error parsing ADO string: %v", err)
+	}
+
+	if ado.Host != "db" || ado.Instance != "SQLEXPRESS" || ado.Password != "s3cret" || ado.Database != "realorm" {
+		t.Errorf("unexpected config %#v", ado)
+	}
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+// PasswordProvider returns the password of a connection. It is called for
+// every new connection of the pool, so rotated credentials are picked up
+// without restarting.
+type PasswordProvider interface {
+	Password(ctx context.Context) (string, error)
+}
+
+// PasswordFunc adapts a function to a PasswordProvider.
+type PasswordFunc func(ctx context.Context) (string, error)
+
+func (f PasswordFunc) Password(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// FilePassword reads the password from a file, like the
+// /run/secrets/db_password of a docker or kubernetes secret.
+// Trailing newlines are removed.
+type FilePassword string
+
+func (f FilePassword) Password(ctx context.Context) (string, error) {
+	data, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvPassword reads the password from an environment variable.
+type EnvPassword string
+
+func (e EnvPassword) Password(ctx context.Context) (string, error) {
+	password, ok := os.LookupEnv(string(e))
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", string(e))
+	}
+	return password, nil
+}
+
+// CommandPassword runs a command, like a secrets manager CLI, and reads the
+// password from its standard output. The first element is the program, the
+// others its arguments. Trailing newlines are removed.
+type CommandPassword []string
+
+func (c CommandPassword) Password(ctx context.Context) (string, error) {
+	if len(c) == 0 {
+		return "", errors.New("password command is empty")
+	}
+
+	// stderr is discarded, it may echo the secret
+	out, err := exec.CommandContext(ctx, c[0], c[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("password command %s: %w", c[0], err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// Returns a dialector for the settings of c a DSN cannot hold: a password
+// provider, asked for the password of every new connection, and the TLS
+// server name of postgres. It returns nil if c has neither.
+func customDialector(c Config) (gorm.Dialector, error) {
+	switch c := c.(type) {
+	case *PostgresConfig:
+		if c.PasswordProvider == nil && c.SSLServerName == "" {
+			return nil, nil
+		}
+
+		connConfig, err := pgx.ParseConfig(c.DSN())
+		if err != nil {
+			return nil, err
+		}
+
+		if c.SSLServerName != "" {
+			setServerName(connConfig, c.SSLServerName)
+		}
+
+		var options []stdlib.OptionOpenDB
+		if c.PasswordProvider != nil {
+			options = append(options, stdlib.OptionBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+				password, err := c.PasswordProvider.Password(ctx)
+				if err != nil {
+					return fmt.Errorf("cannot get password: %w", err)
+				}
+
+				cc.Password = password
+				return nil
+			}))
+		}
+
+		sqlDB := stdlib.OpenDB(*connConfig, options...)
+
+		return postgres.New(postgres.Config{Conn: sqlDB}), nil
+	case *MySQLConfig:
+		if c.PasswordProvider == nil {
+			return nil, nil
+		}
+
+		cfg, err := mysql.ParseDSN(c.DSN())
+		if err != nil {
+			return nil, err
+		}
+
+		sqlDB := sql.OpenDB(&passwordConnector{config: cfg, provider: c.PasswordProvider})
+		return gormmysql.New(gormmysql.Config{Conn: sqlDB}), nil
+	case *SQLServerConfig:
+		if c.PasswordProvider == nil {
+			return nil, nil
+		}
+
+		connector, err := mssql.NewConnector(c.DSN())
+		if err != nil {
+			return nil, err
+		}
+
+		sqlDB := sql.OpenDB(&sqlServerPasswordConnector{config: *c, provider: c.PasswordProvider, driver: connector.Driver()})
+		return sqlserver.New(sqlserver.Config{Conn: sqlDB}), nil
+	}
+
+	return nil, nil
+}
+
+// passwordConnector opens mysql connections with the password of provider.
+type passwordConnector struct {
+	config   *mysql.Config
+	provider PasswordProvider
+}
+
+func (c *passwordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.provider.Password(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get password: %w", err)
+	}
+
+	config := c.config.Clone()
+	config.Passwd = password
+
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *passwordConnector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
+
+// sqlServerPasswordConnector opens sqlserver connections with the password
+// of provider.
+type sqlServerPasswordConnector struct {
+	config   SQLServerConfig
+	provider PasswordProvider
+	driver   driver.Driver
+}
+
+func (c *sqlServerPasswordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.provider.Password(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get password: %w", err)
+	}
+
+	config := c.config
+	config.Password = password
+
+	connector, err := mssql.NewConnector(config.DSN())
+	if err != nil {
+		// never echo the error, it may hold the password
+		return nil, fmt.Errorf("cannot parse DSN. invalid sqlserver DSN")
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *sqlServerPasswordConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlserver"
+)
+
+func TestPasswordProviders(t *testing.T) {
+	ctx := context.Background()
+	file := writeFile(t, "db_password", "s3cret\n")
+	t.Setenv("DB_PASSWORD", "s3cret")
+
+	providers := []PasswordProvider{
+		FilePassword(file),
+		EnvPassword("DB_PASSWORD"),
+		CommandPassword{"echo", "s3cret"},
+		PasswordFunc(func(ctx context.Context) (string, error) { return "s3cret", nil }),
+	}
+
+	for _, p := range providers {
+		password, err := p.Password(ctx)
+		if err != nil || password != "s3cret" {
+			t.Errorf("%T: expected s3cret, got %q and %v", p, password, err)
+		}
+	}
+
+	failing := []PasswordProvider{
+		FilePassword(file + ".missing"),
+		EnvPassword("REALORM_UNSET_PASSWORD"),
+		CommandPassword{"false"},
+		CommandPassword{},
+	}
+
+	for _, p := range failing {
+		if _, err := p.Password(ctx); err == nil {
+			t.Errorf("%T: expected error", p)
+		}
+	}
+}
+
+func TestPasswordProviderPerConnection(t *testing.T) {
+	calls := 0
+	provider := PasswordFunc(func(ctx context.Context) (string, error) {
+		calls++
+		return "", errors.New("vault is sealed")
+	})
+
+	configs := []Config{
+		&PostgresConfig{Host: "127.0.0.1", Port: 1, User: "realorm", Database: "realorm", PasswordProvider: provider},
+		&MySQLConfig{Host: "127.0.0.1", Port: 1, User: "realorm", PasswordProvider: provider},
+		&SQLServerConfig{Host: "127.0.0.1", Port: 1, User: "sa", PasswordProvider: provider},
+	}
+
+	for _, c := range configs {
+		calls = 0
+
+		dialector, err := customDialector(c)
+		if err != nil {
+			t.Fatalf("error creating dialector: %v", err)
+		}
+
+		var db *sql.DB
+		switch d := dialector.(type) {
+		case *postgres.Dialector:
+			db = d.Conn.(*sql.DB)
+		case *gormmysql.Dialector:
+			db = d.Conn.(*sql.DB)
+		case *sqlserver.Dialector:
+			db = d.Conn.(*sql.DB)
+		}
+
+		// every new connection asks for the password again
+		for i := 0; i < 2; i++ {
+			err := db.PingContext(context.Background())
+			if err == nil || !strings.Contains(err.Error(), "vault is sealed") {
+				t.Errorf("%T: expected the provider error, got %v", c, err)
+			}
+		}
+
+		if calls < 2 {
+			t.Errorf("%T: expected the password to be fetched for each connection, got %d calls", c, calls)
+		}
+	}
+
+	if err := (&PostgresConfig{Host: "localhost", User: "realorm", Database: "realorm", Password: "x", PasswordProvider: provider}).Validate(); err == nil {
+		t.Errorf("expected error validating a config with a password and a provider")
+	}
+
+	if err := (&SQLServerConfig{User: "sa", Password: "x", PasswordProvider: provider}).Validate(); err == nil {
+		t.Errorf("expected error validating a sqlserver config with a password and a provider")
+	}
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLiteConfig holds the settings of a sqlite database.
+type SQLiteConfig struct {
+	// Path of the database file, or ":memory:"
+	Path string
+
+	// Journal mode: DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF
+	JournalMode string
+	// Time a statement waits for a locked database before failing
+	BusyTimeout time.Duration
+	// Enforce foreign key constraints
+	ForeignKeys bool
+	// Cache mode: shared or private
+	Cache string
+	// Access mode: ro, rw, rwc or memory
+	Mode string
+
+	// Use the pure Go driver of the SQLITE dialect, which builds without cgo
+	PureGo bool
+
+	// Other parameters passed to the driver as is
+	Params map[string]string
+}
+
+func (c *SQLiteConfig) Dialect() DialectString {
+	if c.PureGo {
+		return SQLITE
+	}
+	return SQLITE3
+}
+
+// Validate returns an error naming the first invalid setting of c.
+func (c *SQLiteConfig) Validate() error {
+	switch {
+	case c.Path == "":
+		return fmt.Errorf("invalid sqlite config: path is empty")
+	case c.BusyTimeout < 0:
+		return fmt.Errorf("invalid sqlite config: busy_timeout is negative")
+	}
+
+	if !oneOf(strings.ToUpper(c.JournalMode), "", "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF") {
+		return fmt.Errorf("invalid sqlite config: unknown journal_mode %q", c.JournalMode)
+	}
+
+	if !oneOf(c.Cache, "", "shared", "private") {
+		return fmt.Errorf("invalid sqlite config: unknown cache %q", c.Cache)
+	}
+
+	if !oneOf(c.Mode, "", "ro", "rw", "rwc", "memory") {
+		return fmt.Errorf("invalid sqlite config: unknown mode %q", c.Mode)
+	}
+	return nil
+}
+
+// DSN returns c as a mattn/go-sqlite3 connection string, like
+// "file:app.db?_busy_timeout=5000&_foreign_keys=1&_journal_mode=WAL".
+// With PureGo, the settings are glebarez/go-sqlite pragmas, like
+// "file:app.db?_pragma=busy_timeout%285000%29".
+func (c *SQLiteConfig) DSN() string {
+	params := url.Values{}
+	for key, value := range c.Params {
+		params.Set(key, value)
+	}
+
+	pragma := func(name, value string) {
+		if c.PureGo {
+			params.Add("_pragma", name+"("+value+")")
+		} else {
+			params.Set("_"+name, value)
+		}
+	}
+
+	// busy_timeout first, so it applies to the other pragmas
+	if c.BusyTimeout > 0 {
+		pragma("busy_timeout", strconv.FormatInt(c.BusyTimeout.Milliseconds(), 10))
+	}
+
+	if c.JournalMode != "" {
+		pragma("journal_mode", strings.ToUpper(c.JournalMode))
+	}
+
+	if c.ForeignKeys {
+		pragma("foreign_keys", "1")
+	}
+
+	if c.Cache != "" {
+		params.Set("cache", c.Cache)
+	}
+
+	if c.Mode != "" {
+		params.Set("mode", c.Mode)
+	}
+
+	// sqlite decodes the %XX escapes of URI file names
+	path := strings.NewReplacer("%", "%25", "?", "%3F", "#", "%23").Replace(c.Path)
+	if len(params) == 0 && path == c.Path {
+		return c.Path
+	}
+
+	dsn := "file:" + path
+	if len(params) > 0 {
+		dsn += "?" + params.Encode()
+	}
+	return dsn
+}
+
+// Returns true if s is one of values.
+func oneOf(s string, values ...string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSQLiteDSN parses a sqlite connection string, a path or a file: URI
+// as built by DSN. pureGo selects the parameters of the pure Go driver,
+// which sets pragmas with _pragma=name(value).
+func ParseSQLiteDSN(dsn string, pureGo bool) (*SQLiteConfig, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("cannot parse DSN. %s", "DSN is empty")
+	}
+
+	c := &SQLiteConfig{Path: dsn, PureGo: pureGo}
+	if !strings.HasPrefix(dsn, "file:") {
+		return c, nil
+	}
+
+	path, query, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+
+	var err error
+	if c.Path, err = url.PathUnescape(path); err != nil {
+		return nil, fmt.Errorf("cannot parse DSN. invalid path")
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse DSN. invalid parameters")
+	}
+
+	// settings of the mattn driver, or pragmas of the pure Go driver
+	settings := map[string]string{}
+	for key, values := range params {
+		if pureGo && key == "_pragma" {
+			for _, value := range values {
+				name, arg, ok := strings.Cut(strings.TrimSuffix(value, ")"), "(")
+				if !ok || !oneOf(name, "journal_mode", "busy_timeout", "foreign_keys") {
+					if c.Params == nil {
+						c.Params = map[string]string{}
+					}
+					// other pragmas are kept, the last one wins
+					c.Params[key] = value
+					continue
+				}
+				settings[name] = arg
+			}
+			continue
+		}
+
+		value := values[len(values)-1]
+		switch {
+		case key == "cache":
+			c.Cache = value
+		case key == "mode":
+			c.Mode = value
+		case !pureGo && oneOf(key, "_journal_mode", "_busy_timeout", "_foreign_keys"):
+			settings[strings.TrimPrefix(key, "_")] = value
+		default:
+			if c.Params == nil {
+				c.Params = map[string]string{}
+			}
+			c.Params[key] = value
+		}
+	}
+
+	c.JournalMode = settings["journal_mode"]
+
+	if value, ok := settings["busy_timeout"]; ok {
+		ms, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse DSN. invalid busy_timeout %q", value)
+		}
+		c.BusyTimeout = time.Duration(ms) * time.Millisecond
+	}
+
+	if value, ok := settings["foreign_keys"]; ok {
+		c.ForeignKeys = oneOf(strings.ToLower(value), "1", "true", "yes", "on")
+	}
+
+	return c, nil
+}
@@ -0,0 +1,39 @@
+//go:build cgo
+
+package database
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+)
+
+// SQLite dialect of the build: SQLITE3 with cgo, SQLITE without.
+const SQLITE_DEFAULT = SQLITE3
+
+// The mattn/go-sqlite3 driver requires cgo, so builds without it do not
+// link it and do not know the SQLITE3 dialect.
+func init() {
+	RegisterDialect(SQLITE3, DialectFactory{
+		Open: sqlite.Open,
+		ParseDSN: func(dsn string) (Config, error) {
+			return configOrError(ParseSQLiteDSN(dsn, false))
+		},
+		TranslateError: translateSQLiteError,
+		Features:       Features{Returning: true, Upsert: UpsertOnConflict, MaxParams: 32766},
+	})
+}
+
+// Returns the extended result code of a mattn/go-sqlite3 error.
+func cgoSQLiteCode(err error) (int, bool) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return 0, false
+	}
+
+	if sqliteErr.ExtendedCode == 0 {
+		return int(sqliteErr.Code), true
+	}
+	return int(sqliteErr.ExtendedCode), true
+}
@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package database
+
+// SQLite dialect of the build: SQLITE3 with cgo, SQLITE without, as
+// mattn/go-sqlite3 requires cgo. SQLITE3 is not registered in this build.
+const SQLITE_DEFAULT = SQLITE
+
+// Without cgo there are no mattn/go-sqlite3 errors.
+func cgoSQLiteCode(err error) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteConfig(t *testing.T) {
+	c := &SQLiteConfig{
+		Path:        filepath.Join(t.TempDir(), "realorm?.db"),
+		JournalMode: "wal",
+		BusyTimeout: 5 * time.Second,
+		ForeignKeys: true,
+	}
+
+	if !strings.HasSuffix(c.DSN(), "realorm%3F.db?_busy_timeout=5000&_foreign_keys=1&_journal_mode=WAL") {
+		t.Errorf("unexpected dsn %s", c.DSN())
+	}
+
+	c.PureGo = SQLITE_DEFAULT == SQLITE
+	db, err := Connect(c, SQLITE_DEFAULT)
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+
+	var journalMode string
+	var foreignKeys int
+	db.Raw("PRAGMA journal_mode").Scan(&journalMode)
+	db.Raw("PRAGMA foreign_keys").Scan(&foreignKeys)
+
+	if journalMode != "wal" || foreignKeys != 1 {
+		t.Errorf("expected wal journal and foreign keys, got %s and %d", journalMode, foreignKeys)
+	}
+
+	if (&SQLiteConfig{Path: ":memory:"}).DSN() != ":memory:" {
+		t.Errorf("expected a plain path")
+	}
+
+	invalid := []*SQLiteConfig{
+		{},
+		{Path: "app.db", JournalMode: "fast"},
+		{Path: "app.db", Cache: "global"},
+		{Path: "app.db", Mode: "rx"},
+	}
+
+	for _, c := range invalid {
+		c.PureGo = SQLITE_DEFAULT == SQLITE
+		if _, err := Connect(c, SQLITE_DEFAULT); err == nil {
+			t.Errorf("expected error connecting with %+v", c)
+		}
+	}
+}
+
+func TestSQLiteConfigPureGo(t *testing.T) {
+	c := &SQLiteConfig{
+		Path:        filepath.Join(t.TempDir(), "realorm.db"),
+		JournalMode: "wal",
+		BusyTimeout: 5 * time.Second,
+		ForeignKeys: true,
+		PureGo:      true,
+	}
+
+	if c.Dialect() != SQLITE {
+		t.Errorf("expected dialect %s, got %s", SQLITE, c.Dialect())
+	}
+
+	if !strings.HasSuffix(c.DSN(), "realorm.db?_pragma=busy_timeout%285000%29&_pragma=journal_mode%28WAL%29&_pragma=foreign_keys%281%29") {
+		t.Errorf("unexpected dsn %s", c.DSN())
+	}
+
+	if _, err := Connect(c, SQLITE3); err == nil {
+		t.Errorf("expected error connecting to sqlite3 with a pure Go config")
+	}
+
+	db, err := Connect(c, SQLITE)
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+
+	var journalMode string
+	var foreignKeys, busyTimeout int
+	db.Raw("PRAGMA journal_mode").Scan(&journalMode)
+	db.Raw("PRAGMA foreign_keys").Scan(&foreignKeys)
+	db.Raw("PRAGMA busy_timeout").Scan(&busyTimeout)
+
+	if journalMode != "wal" || foreignKeys != 1 || busyTimeout != 5000 {
+		t.Errorf("expected wal journal, foreign keys and busy timeout, got %s, %d and %d", journalMode, foreignKeys, busyTimeout)
+	}
+
+	memory, err := Connect(SQLITE3_MEMORY_DB, SQLITE)
+	if err != nil {
+		t.Fatalf("error connecting to memory database: %v", err)
+	}
+
+	if err := memory.Exec("CREATE TABLE IF NOT EXISTS notes (body TEXT)").Error; err != nil {
+		t.Errorf("error creating table: %v", err)
+	}
+}
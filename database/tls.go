@@ -0,0 +1,161 @@
+package database
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v4"
+)
+
+// Checks the TLS files of a config: they must exist, and a client
+// certificate requires its key.
+func checkTLSFiles(ca, cert, key string) error {
+	if (cert == "") != (key == "") {
+		return errors.New("client certificate and key must be set together")
+	}
+
+	for _, file := range []string{ca, cert, key} {
+		if file == "" {
+			continue
+		}
+
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Builds a tls.Config trusting the certificates of the ca file, or the
+// system roots if it is empty, and presenting the client certificate, if any.
+// verify is one of "verify-full", "verify-ca" or "skip-verify".
+func newTLSConfig(verify string, ca, cert, key, serverName string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", ca)
+		}
+	}
+
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	switch verify {
+	case "skip-verify":
+		config.InsecureSkipVerify = true
+	case "verify-ca":
+		// verify the chain but not the host name
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(raw, config.RootCAs)
+		}
+	}
+
+	return config, nil
+}
+
+// Verifies that the certificates in raw chain up to roots.
+func verifyChain(raw [][]byte, roots *x509.CertPool) error {
+	if len(raw) == 0 {
+		return errors.New("server presented no certificate")
+	}
+
+	certs := make([]*x509.Certificate, len(raw))
+	for i, der := range raw {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+// Returns true if c needs a tls.Config registered with the mysql driver.
+func (c *MySQLConfig) hasCustomTLS() bool {
+	return c.TLSCAFile != "" || c.TLSCertFile != "" || c.TLSServerName != "" || c.TLS == "verify-full" || c.TLS == "verify-ca"
+}
+
+// Returns how a custom tls.Config of c verifies the server.
+func (c *MySQLConfig) tlsVerify() string {
+	switch c.TLS {
+	case "skip-verify", "verify-ca":
+		return c.TLS
+	}
+	return "verify-full"
+}
+
+// Returns the name c registers its tls.Config under, derived from its
+// settings so equal configs share a registration.
+func (c *MySQLConfig) tlsName() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%s|%s", c.tlsVerify(), c.TLSCAFile, c.TLSCertFile, c.TLSKeyFile, c.TLSServerName, c.Host)))
+	return "realorm-" + hex.EncodeToString(sum[:8])
+}
+
+// RegisterTLS registers the tls.Config built from the TLS files of c with
+// the mysql driver and returns its name, which DSN uses as the tls parameter.
+// Connect calls it, call it before opening the DSN of c yourself.
+// It does nothing and returns an empty name if c has no TLS files.
+func (c *MySQLConfig) RegisterTLS() (string, error) {
+	if !c.hasCustomTLS() {
+		return "", nil
+	}
+
+	serverName := c.TLSServerName
+	if serverName == "" && c.Net != "unix" {
+		serverName = c.Host
+		if serverName == "" {
+			serverName = "127.0.0.1"
+		}
+	}
+
+	config, err := newTLSConfig(c.tlsVerify(), c.TLSCAFile, c.TLSCertFile, c.TLSKeyFile, serverName)
+	if err != nil {
+		return "", err
+	}
+
+	name := c.tlsName()
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Sets the server name checked by the TLS configs of a postgres connection
+// and its fallbacks.
+func setServerName(config *pgx.ConnConfig, serverName string) {
+	if config.TLSConfig != nil {
+		config.TLSConfig.ServerName = serverName
+	}
+
+	for _, fallback := range config.Fallbacks {
+		if fallback.TLSConfig != nil {
+			fallback.TLSConfig.ServerName = serverName
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/driver/postgres"
+)
+
+// Writes a certificate for host signed by parent, or self-signed if parent
+// is nil, and returns the files of the certificate and its key.
+func writeCert(t *testing.T, name string, host string, parent *tls.Certificate) (string, string, tls.Certificate) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	issuer, signer := template, any(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile := writeFile(t, name+".crt", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	keyFile := writeFile(t, name+".key", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+
+	leaf, _ := x509.ParseCertificate(der)
+	return certFile, keyFile, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestMySQLTLS(t *testing.T) {
+	caFile, _, ca := writeCert(t, "ca", "realorm-ca", nil)
+	certFile, keyFile, _ := writeCert(t, "client", "client", &ca)
+
+	c := &MySQLConfig{User: "realorm", Host: "db", TLSCAFile: caFile, TLSCertFile: certFile, TLSKeyFile: keyFile}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("error validating config: %v", err)
+	}
+
+	name, err := c.RegisterTLS()
+	if err != nil {
+		t.Fatalf("error registering tls config: %v", err)
+	}
+
+	if !strings.HasPrefix(name, "realorm-") || !strings.Contains(c.DSN(), "tls="+name) {
+		t.Errorf("expected the dsn to use tls config %s, got %s", name, c.DSN())
+	}
+
+	// verify modes without files use a registered config trusting the
+	// system roots, the driver knows no such modes
+	for _, mode := range []string{"verify-full", "verify-ca"} {
+		c := &MySQLConfig{User: "realorm", Host: "db", TLS: mode}
+		if err := c.Validate(); err != nil {
+			t.Fatalf("error validating tls %s: %v", mode, err)
+		}
+
+		if _, err := c.RegisterTLS(); err != nil {
+			t.Fatalf("error registering tls %s: %v", mode, err)
+		}
+
+		if _, err := mysql.ParseDSN(c.DSN()); err != nil || strings.Contains(c.DSN(), "tls="+mode) {
+			t.Errorf("expected a registered tls config for %s, got %s and %v", mode, c.DSN(), err)
+		}
+	}
+
+	invalid := []*MySQLConfig{
+		{User: "realorm", TLSCAFile: caFile, TLS: "preferred"},
+		{User: "realorm", TLSCertFile: certFile},
+		{User: "realorm", TLSCAFile: caFile + ".missing"},
+	}
+
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected error validating %+v", c)
+		}
+	}
+}
+
+func TestVerifyCA(t *testing.T) {
+	caFile, _, ca := writeCert(t, "ca", "realorm-ca", nil)
+	_, _, server := writeCert(t, "server", "db.internal", &ca)
+	_, _, other := writeCert(t, "other", "db.internal", nil)
+
+	config, err := newTLSConfig("verify-ca", caFile, "", "", "wrong-host")
+	if err != nil {
+		t.Fatalf("error creating tls config: %v", err)
+	}
+
+	// the host name is not checked
+	if err := config.VerifyPeerCertificate(server.Certificate, nil); err != nil {
+		t.Errorf("expected the server certificate to verify, got %v", err)
+	}
+
+	if err := config.VerifyPeerCertificate(other.Certificate, nil); err == nil {
+		t.Errorf("expected a certificate of another ca to fail")
+	}
+}
+
+func TestPostgresTLS(t *testing.T) {
+	caFile, _, ca := writeCert(t, "ca", "realorm-ca", nil)
+	_, _, server := writeCert(t, "server", "db.internal", &ca)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer listener.Close()
+
+	// answers the SSLRequest of postgres and records the server name of the
+	// TLS handshake
+	serverName := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		io.ReadFull(conn, make([]byte, 8))
+		conn.Write([]byte("S"))
+
+		tls.Server(conn, &tls.Config{
+			Certificates: []tls.Certificate{server},
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				serverName <- hello.ServerName
+				return nil, nil
+			},
+		}).Handshake()
+	}()
+
+	port, _ := strconv.Atoi(strings.Split(listener.Addr().String(), ":")[1])
+	c := &PostgresConfig{
+		Host:          "127.0.0.1",
+		Port:          port,
+		User:          "realorm",
+		Password:      "password",
+		Database:      "realorm",
+		SSLMode:       "verify-full",
+		SSLRootCert:   caFile,
+		SSLServerName: "db.internal",
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("error validating config: %v", err)
+	}
+
+	dialector, err := customDialector(c)
+	if err != nil {
+		t.Fatalf("error creating dialector: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dialector.(*postgres.Dialector).Conn.(*sql.DB).PingContext(ctx)
+
+	select {
+	case name := <-serverName:
+		if name != "db.internal" {
+			t.Errorf("expected server name db.internal, got %s", name)
+		}
+	case <-ctx.Done():
+		t.Fatalf("no TLS handshake")
+	}
+
+	invalid := []*PostgresConfig{
+		{Host: "localhost", User: "realorm", Database: "realorm", SSLServerName: "db.internal"},
+		{Host: "localhost", User: "realorm", Database: "realorm", SSLMode: "require", SSLCert: caFile},
+		{Host: "localhost", User: "realorm", Database: "realorm", SSLMode: "require", SSLRootCert: filepath.Join(t.TempDir(), "missing.crt")},
+	}
+
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected error validating %+v", c)
+		}
+	}
+}
@@ -19,6 +19,7 @@ type Post struct {
 var (
 	// DSN is the database connection string
 	DSN     = flag.String("dsn", "", "database connection string")
+	CONFIG  = flag.String("config", "", "JSON or .env file of REALORM_* settings, used if dsn is empty")
 	MIGRATE = flag.Bool("migrate", false, "migrate the database schema")
 )
 
@@ -26,7 +27,22 @@ func main() {
 	flag.Parse()
 
 	// create the orm
-	orm := realorm.New(*DSN, database.PG)
+	var orm realorm.ORM
+	if *DSN != "" {
+		orm = realorm.New(*DSN, database.PG)
+	} else {
+		var files []string
+		if *CONFIG != "" {
+			files = append(files, *CONFIG)
+		}
+
+		config, err := database.LoadConfig(files...)
+		if err != nil {
+			log.Fatalf("config error: %v\n", err)
+		}
+
+		orm = realorm.New(config, config.Dialect())
+	}
 
 	// create the post
 	err := orm.Create(&Post{
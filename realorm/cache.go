@@ -0,0 +1,491 @@
+package realorm
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abiiranathan/realorm/database"
+	"gorm.io/gorm"
+)
+
+// Cache stores the results of Find, FindAll and FindAllPaginated.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored under key
+	Get(key string) (any, bool)
+	// Set stores value under key. tables are the tables the value was read from.
+	Set(key string, value any, tables ...string)
+	// Invalidate removes all values read from table
+	Invalidate(table string)
+}
+
+// A cached result
+type cacheEntry struct {
+	value any
+	count int64
+}
+
+// Generations of the tables of a cache, incremented on every invalidation.
+// A read is only cached if the generations of its tables did not change
+// while it ran, so a read racing with a write never caches the rows from
+// before the write.
+type cacheGenerations struct {
+	mu     sync.Mutex
+	tables map[string]uint64
+}
+
+// Returns the generations of tables.
+func (g *cacheGenerations) get(tables []string) []uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	generations := make([]uint64, len(tables))
+	for i, table := range tables {
+		generations[i] = g.tables[table]
+	}
+	return generations
+}
+
+func (g *cacheGenerations) increment(table string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.tables[table]++
+}
+
+// Caches attached with WithCache to the copies of an orm, with the
+// generations of their tables. It is shared by all copies, so writes through
+// any copy, like the ORM WithCache was called on or one of ForTenant,
+// invalidate every cache.
+type cacheSet struct {
+	mu     sync.Mutex
+	caches map[Cache]*cacheGenerations
+}
+
+func newCacheSet() *cacheSet {
+	return &cacheSet{caches: map[Cache]*cacheGenerations{}}
+}
+
+// Returns the generations of c, attaching c if needed.
+func (s *cacheSet) attach(c Cache) *cacheGenerations {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.caches[c]
+	if !ok {
+		g = &cacheGenerations{tables: map[string]uint64{}}
+		s.caches[c] = g
+	}
+	return g
+}
+
+// Returns true if no cache is attached.
+func (s *cacheSet) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.caches) == 0
+}
+
+// Invalidates the cached reads of table in every cache and the reads of it
+// in flight.
+func (s *cacheSet) invalidate(table string) {
+	s.mu.Lock()
+	caches := make(map[Cache]*cacheGenerations, len(s.caches))
+	for c, g := range s.caches {
+		caches[c] = g
+	}
+	s.mu.Unlock()
+
+	for c, g := range caches {
+		g.increment(table)
+		c.Invalidate(table)
+	}
+}
+
+// WithCache returns an ORM caching reads in c, which must be comparable,
+// like a pointer.
+// Create, Update and Delete through this ORM or any other copy of the ORM
+// WithCache was called on invalidate the cached reads of their table.
+func (o *orm) WithCache(c Cache) ORM {
+	clone := o.clone()
+	clone.cache = c
+	clone.generations = o.caches.attach(c)
+	clone.caching = true
+	return clone
+}
+
+// Cached returns an ORM whose reads use the cache.
+func (o *orm) Cached() ORM {
+	c := o.clone()
+	c.caching = true
+	return c
+}
+
+// NoCache returns an ORM whose reads bypass the cache.
+// Writes still invalidate it.
+func (o *orm) NoCache() ORM {
+	c := o.clone()
+	c.caching = false
+	return c
+}
+
+// Returns the tables read when loading model, including the tables of its
+// preloaded associations.
+func cacheTables(db *gorm.DB, model any) []string {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil
+	}
+
+	tables := []string{stmt.Table}
+	for _, rel := range stmt.Schema.Relationships.Relations {
+		tables = append(tables, rel.FieldSchema.Table)
+	}
+	return tables
+}
+
+// Returns the namespace of the cache keys of the database at dsn, a hash of
+// its dialect and DSN without password, the same in every process.
+func cacheNamespace(dsn any, dialect database.DialectString) string {
+	s, _ := dsn.(string)
+	if c, ok := dsn.(database.Config); ok {
+		s = c.DSN()
+	}
+
+	sum := sha256.Sum256([]byte(string(dialect) + "\x00" + database.RedactDSN(s)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Returns the cache key of a read. ok is false if the read is not cached.
+// Reads into a model with a primary key are restricted to that key, so the
+// key is part of the cache key.
+func (o *orm) cacheKey(op string, model any, where *WhereClause, page, pageSize int) (key string, ok bool) {
+	if o.cache == nil || !o.caching {
+		return "", false
+	}
+
+	key = fmt.Sprintf("%s:%s:%T:%s:%d:%d", o.namespace, op, model, keyValue(o.tenant), page, pageSize)
+	if pk := primaryKeyValues(o.DB, model); len(pk) > 0 {
+		key += ":" + keyValue(pk)
+	}
+
+	if where != nil {
+		key += fmt.Sprintf(":%s:%s", where.Query, keyValue(where.Args))
+	}
+	return key, true
+}
+
+// Formats v for a cache key. Pointers are formatted as the values they
+// point to, so equal queries get equal keys whatever the address of their
+// arguments.
+func keyValue(v any) string {
+	var b strings.Builder
+	writeKeyValue(&b, reflect.ValueOf(v))
+	return b.String()
+}
+
+// Writes v to b, see keyValue.
+func writeKeyValue(b *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		b.WriteString("nil")
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			fmt.Fprintf(b, "%s(nil)", v.Type())
+			return
+		}
+		writeKeyValue(b, v.Elem())
+	case reflect.Slice, reflect.Array:
+		fmt.Fprintf(b, "%s{", v.Type())
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeKeyValue(b, v.Index(i))
+		}
+		b.WriteString("}")
+	default:
+		fmt.Fprintf(b, "%#v", v.Interface())
+	}
+}
+
+// Returns the non-zero primary key values of model, a pointer to a struct.
+func primaryKeyValues(db *gorm.DB, model any) []any {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil
+	}
+
+	var values []any
+	for _, field := range stmt.Schema.PrimaryFields {
+		if value, zero := field.ValueOf(context.Background(), v); !zero {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Copies the cached value under key into dest.
+func (o *orm) fromCache(key string, dest any) (*cacheEntry, bool) {
+	v, ok := o.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	entry, ok := v.(*cacheEntry)
+	if !ok {
+		return nil, false
+	}
+
+	target := reflect.ValueOf(dest).Elem()
+	value := copyValue(reflect.ValueOf(entry.value))
+	if !value.Type().AssignableTo(target.Type()) {
+		return nil, false
+	}
+
+	target.Set(value)
+	return entry, true
+}
+
+// Returns the generations of the tables read when loading model, taken
+// before reading it for toCache.
+func (o *orm) cacheGeneration(model any) []uint64 {
+	return o.generations.get(cacheTables(o.DB, model))
+}
+
+// Stores a copy of the value dest points to under key, unless the tables it
+// was read from were invalidated since generation was taken.
+func (o *orm) toCache(key string, dest any, count int64, generation []uint64) {
+	tables := cacheTables(o.DB, dest)
+	if !slices.Equal(o.generations.get(tables), generation) {
+		return
+	}
+
+	value := copyValue(reflect.ValueOf(dest).Elem())
+	o.cache.Set(key, &cacheEntry{value: value.Interface(), count: count}, tables...)
+
+	// an invalidation between the check and Set may have missed the value
+	current := o.generations.get(tables)
+	for i, table := range tables {
+		if current[i] != generation[i] {
+			o.cache.Invalidate(table)
+		}
+	}
+}
+
+// Invalidates the cached reads of the table of model.
+// Inside a transaction, invalidation is deferred until commit.
+func (o *orm) invalidate(model any) {
+	if o.caches.empty() {
+		return
+	}
+
+	stmt := &gorm.Statement{DB: o.DB}
+	if err := stmt.Parse(model); err != nil {
+		return
+	}
+
+	if o.pending != nil {
+		*o.pending = append(*o.pending, stmt.Table)
+		return
+	}
+	o.invalidateTable(stmt.Table)
+}
+
+// Invalidates the cached reads of table and the reads of it in flight.
+func (o *orm) invalidateTable(table string) {
+	o.caches.invalidate(table)
+}
+
+// Returns a deep copy of v, so callers and the cache never share slices,
+// maps or pointers. Unexported fields are copied as is.
+func copyValue(v reflect.Value) reflect.Value {
+	return deepCopy(v, map[uintptr]reflect.Value{})
+}
+
+// Copies v, reusing the copies of the pointers in seen so cycles end.
+func deepCopy(v reflect.Value, seen map[uintptr]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+
+		if c, ok := seen[v.Pointer()]; ok && c.Type() == v.Type() {
+			return c
+		}
+
+		c := reflect.New(v.Type().Elem())
+		seen[v.Pointer()] = c
+		c.Elem().Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value(), seen))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i), seen))
+			}
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem(), seen))
+		return c
+	default:
+		return v
+	}
+}
+
+type lruItem struct {
+	key     string
+	value   any
+	tables  []string
+	expires time.Time
+}
+
+// LRUCache is an in-process Cache holding at most size values, each for at
+// most ttl.
+type LRUCache struct {
+	size int
+	ttl  time.Duration
+
+	mu     sync.Mutex
+	lru    *list.List
+	items  map[string]*list.Element
+	tables map[string]map[string]struct{}
+}
+
+// NewLRUCache returns an LRUCache. A ttl of 0 means values never expire.
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		size:   size,
+		ttl:    ttl,
+		lru:    list.New(),
+		items:  map[string]*list.Element{},
+		tables: map[string]map[string]struct{}{},
+	}
+}
+
+func (c *LRUCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	item := e.Value.(*lruItem)
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		c.remove(e)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+	return item.value, true
+}
+
+func (c *LRUCache) Set(key string, value any, tables ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+
+	item := &lruItem{key: key, value: value, tables: tables}
+	if c.ttl > 0 {
+		item.expires = time.Now().Add(c.ttl)
+	}
+
+	c.items[key] = c.lru.PushFront(item)
+	for _, table := range tables {
+		if c.tables[table] == nil {
+			c.tables[table] = map[string]struct{}{}
+		}
+		c.tables[table][key] = struct{}{}
+	}
+
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *LRUCache) Invalidate(table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.tables[table] {
+		if e, ok := c.items[key]; ok {
+			c.remove(e)
+		}
+	}
+	delete(c.tables, table)
+}
+
+// Len returns the number of cached values.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Removes e from the cache and its table index.
+func (c *LRUCache) remove(e *list.Element) {
+	item := c.lru.Remove(e).(*lruItem)
+	delete(c.items, item.key)
+
+	for _, table := range item.tables {
+		delete(c.tables[table], item.key)
+		if len(c.tables[table]) == 0 {
+			delete(c.tables, table)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package realorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type cachedNode struct {
+	Name     string
+	Tags     []string
+	Meta     map[string]string
+	Children []cachedNode
+	Parent   *cachedNode
+}
+
+func TestCopyValue(t *testing.T) {
+	root := &cachedNode{Name: "root", Meta: map[string]string{"a": "b"}}
+	root.Children = []cachedNode{{Name: "child", Tags: []string{"x"}, Parent: root}}
+	root.Parent = root
+
+	c := copyValue(reflect.ValueOf(root)).Interface().(*cachedNode)
+	if !reflect.DeepEqual(c.Children[0].Tags, root.Children[0].Tags) || c.Meta["a"] != "b" {
+		t.Fatalf("copy differs: %+v", c)
+	}
+
+	// nested values are not shared
+	c.Children[0].Tags[0] = "y"
+	c.Meta["a"] = "c"
+	if root.Children[0].Tags[0] != "x" || root.Meta["a"] != "b" {
+		t.Errorf("copy shares nested values with the original")
+	}
+
+	// cycles are copied as cycles
+	if c.Parent != c || c.Children[0].Parent != c {
+		t.Errorf("expected the copy to point to itself")
+	}
+}
@@ -0,0 +1,273 @@
+package realorm_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/realorm/realorm"
+	"gorm.io/gorm"
+)
+
+func Test_realorm_Cache(t *testing.T) {
+	cache := realorm.NewLRUCache(10, time.Minute)
+	orm := realorm.New(filepath.Join(t.TempDir(), "cache.db"), testDialect).WithCache(cache)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 cached read, got %d", cache.Len())
+	}
+
+	// writes bypassing the ORM are not seen until invalidation
+	orm.GetDB().Create(&Post{ID: UniqueID(), Title: "Direct", Content: "direct"})
+
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 0 {
+		t.Errorf("expected cached result with 0 posts, got %d", len(posts))
+	}
+
+	if err := orm.NoCache().FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 1 {
+		t.Errorf("expected 1 uncached post, got %d", len(posts))
+	}
+
+	// writes through the ORM invalidate the table
+	post := &Post{ID: UniqueID(), Title: "Hello World", Content: "This is a test post"}
+	if err := orm.Create(post); err != nil {
+		t.Fatalf("error creating post: %v\n", err)
+	}
+
+	if cache.Len() != 0 {
+		t.Errorf("expected empty cache after create, got %d", cache.Len())
+	}
+
+	result, err := orm.FindAllPaginated(&posts, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("error paginating posts: %v\n", err)
+	}
+
+	cachedPosts := []Post{}
+	cachedResult, err := orm.FindAllPaginated(&cachedPosts, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("error paginating posts: %v\n", err)
+	}
+
+	if cachedResult.Count != 2 || cachedResult.TotalPages != result.TotalPages || len(cachedPosts) != 1 {
+		t.Errorf("unexpected cached page: %+v", cachedResult)
+	}
+
+	var found Post
+	where := &realorm.WhereClause{Query: "id = ?", Args: []interface{}{post.ID}}
+	if err := orm.Find(&found, where); err != nil {
+		t.Fatalf("error finding post: %v\n", err)
+	}
+
+	err = orm.Transaction(func(tx realorm.ORM) error {
+		_, err := tx.Update(Post{Title: "Updated"}, post.ID, where)
+		return err
+	})
+
+	if err != nil {
+		t.Fatalf("error updating post: %v\n", err)
+	}
+
+	if err := orm.Find(&found, where); err != nil {
+		t.Fatalf("error finding post: %v\n", err)
+	}
+
+	if found.Title != "Updated" {
+		t.Errorf("expected Updated after commit, got %s", found.Title)
+	}
+}
+
+func Test_realorm_LRUCache(t *testing.T) {
+	cache := realorm.NewLRUCache(2, 10*time.Millisecond)
+	cache.Set("a", 1, "posts")
+	cache.Set("b", 2, "posts")
+	cache.Set("c", 3, "users")
+
+	// the least recently used value is evicted
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected a to be evicted")
+	}
+
+	cache.Invalidate("posts")
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected b to be invalidated")
+	}
+
+	if v, ok := cache.Get("c"); !ok || v != 3 {
+		t.Errorf("expected 3, got %v", v)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := cache.Get("c"); ok {
+		t.Errorf("expected c to expire")
+	}
+}
+
+func Test_realorm_CacheKeys(t *testing.T) {
+	dir := t.TempDir()
+	cache := realorm.NewLRUCache(10, time.Minute)
+
+	// databases sharing a cache
+	first := realorm.New(filepath.Join(dir, "first.db"), testDialect).WithCache(cache)
+	second := realorm.New(filepath.Join(dir, "second.db"), testDialect).WithCache(cache)
+
+	for _, orm := range []realorm.ORM{first, second} {
+		if err := orm.Migrate(&Post{}); err != nil {
+			t.Fatalf("error migrating posts: %v\n", err)
+		}
+	}
+
+	if err := first.Create(&Post{ID: 1, Title: "First", Content: "first"}); err != nil {
+		t.Fatalf("error creating post: %v\n", err)
+	}
+
+	if err := first.Create(&Post{ID: 2, Title: "Second", Content: "second"}); err != nil {
+		t.Fatalf("error creating post: %v\n", err)
+	}
+
+	var posts []Post
+	if err := first.FindAll(&posts, nil); err != nil || len(posts) != 2 {
+		t.Fatalf("expected 2 posts, got %d and %v", len(posts), err)
+	}
+
+	if err := second.FindAll(&posts, nil); err != nil || len(posts) != 0 {
+		t.Errorf("expected 0 posts in the second database, got %d and %v", len(posts), err)
+	}
+
+	// the primary key of the model restricts Find
+	where := &realorm.WhereClause{Query: "1 = 1"}
+	for _, id := range []uint{1, 2, 1} {
+		post := Post{ID: id}
+		if err := first.Find(&post, where); err != nil {
+			t.Fatalf("error finding post %d: %v\n", id, err)
+		}
+
+		if post.ID != id {
+			t.Errorf("expected post %d, got %d", id, post.ID)
+		}
+	}
+}
+
+func Test_realorm_CacheInvalidatedDuringRead(t *testing.T) {
+	cache := realorm.NewLRUCache(10, 0)
+	orm := realorm.New(filepath.Join(t.TempDir(), "cache.db"), testDialect).WithCache(cache)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	// a post is created after the first read of posts ran, before it is cached
+	write := true
+	err := orm.GetDB().Callback().Query().After("gorm:query").Register("test:write_during_read", func(db *gorm.DB) {
+		if write && db.Statement.Table == "posts" {
+			write = false
+			if err := orm.Create(&Post{ID: UniqueID(), Title: "Racing", Content: "write"}); err != nil {
+				t.Errorf("error creating post: %v\n", err)
+			}
+		}
+	})
+
+	if err != nil {
+		t.Fatalf("error registering callback: %v\n", err)
+	}
+
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 0 || cache.Len() != 0 {
+		t.Errorf("expected the read from before the write not to be cached, got %d posts and %d entries", len(posts), cache.Len())
+	}
+
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 1 || cache.Len() != 1 {
+		t.Errorf("expected 1 post cached, got %d posts and %d entries", len(posts), cache.Len())
+	}
+}
+
+func Test_realorm_CachePointerArgs(t *testing.T) {
+	cache := realorm.NewLRUCache(10, 0)
+	orm := realorm.New(filepath.Join(t.TempDir(), "cache.db"), testDialect).WithCache(cache)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	// equal arguments at different addresses share a cache entry
+	var posts []Post
+	for i := 0; i < 3; i++ {
+		title := "Hello"
+		where := &realorm.WhereClause{Query: "title = ?", Args: []interface{}{&title}}
+		if err := orm.FindAll(&posts, where); err != nil {
+			t.Fatalf("error finding posts: %v\n", err)
+		}
+	}
+
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 cached read, got %d", cache.Len())
+	}
+
+	other := "World"
+	if err := orm.FindAll(&posts, &realorm.WhereClause{Query: "title = ?", Args: []interface{}{&other}}); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 cached reads, got %d", cache.Len())
+	}
+}
+
+func Test_realorm_CacheInvalidatedByCopies(t *testing.T) {
+	cache := realorm.NewLRUCache(10, 0)
+	plain := realorm.New(filepath.Join(t.TempDir(), "cache.db"), testDialect)
+	cached := plain.WithCache(cache)
+	if err := plain.Migrate(&Post{}, &Invoice{}); err != nil {
+		t.Fatalf("error migrating: %v\n", err)
+	}
+
+	var posts []Post
+	if err := cached.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	// a write through the ORM WithCache was called on
+	if err := plain.Create(&Post{ID: UniqueID(), Title: "Plain", Content: "plain"}); err != nil {
+		t.Fatalf("error creating post: %v\n", err)
+	}
+
+	if err := cached.FindAll(&posts, nil); err != nil || len(posts) != 1 {
+		t.Errorf("expected 1 post after a write through the plain ORM, got %d and %v", len(posts), err)
+	}
+
+	var invoices []Invoice
+	if err := cached.ForTenant(uint(1)).FindAll(&invoices, nil); err != nil {
+		t.Fatalf("error finding invoices: %v\n", err)
+	}
+
+	// a write through a tenant copy of the plain ORM
+	if err := plain.ForTenant(uint(1)).Create(&Invoice{ID: UniqueID(), Number: "INV-1"}); err != nil {
+		t.Fatalf("error creating invoice: %v\n", err)
+	}
+
+	if err := cached.ForTenant(uint(1)).FindAll(&invoices, nil); err != nil || len(invoices) != 1 {
+		t.Errorf("expected 1 invoice after a write through a tenant copy, got %d and %v", len(invoices), err)
+	}
+}
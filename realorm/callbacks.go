@@ -0,0 +1,56 @@
+package realorm
+
+import (
+	"github.com/abiiranathan/realorm/database"
+	"gorm.io/gorm"
+)
+
+// Returns the orm of db, just connected to dsn, with its callbacks
+// registered.
+func newORM(db *gorm.DB, dsn any, dialect database.DialectString) *orm {
+	registerCallbacks(db)
+	return &orm{
+		DB:         db,
+		dialect:    dialect,
+		namespace:  cacheNamespace(dsn, dialect),
+		caches:     newCacheSet(),
+		migrations: &migrations{},
+		gate:       newGate(),
+	}
+}
+
+// Registers the callbacks run around every statement of db. gorm compiles
+// callbacks on registration without locking, so it must be called before
+// db runs any statement.
+func registerCallbacks(db *gorm.DB) {
+	const name = "realorm:statement"
+	const start = "realorm:statement_start"
+
+	cb := db.Callback()
+	if cb.Query().Get(name) != nil {
+		return
+	}
+
+	cb.Create().Before("gorm:create").Register(start, startStatement)
+	cb.Query().Before("gorm:query").Register(start, startStatement)
+	cb.Update().Before("gorm:update").Register(start, startStatement)
+	cb.Delete().Before("gorm:delete").Register(start, startStatement)
+	cb.Row().Before("gorm:row").Register(start, startStatement)
+	cb.Raw().Before("gorm:raw").Register(start, startStatement)
+
+	cb.Create().After("gorm:create").Register(name, afterStatement(StatementInsert))
+	cb.Query().After("gorm:query").Register(name, afterStatement(StatementSelect))
+	cb.Update().After("gorm:update").Register(name, afterStatement(StatementUpdate))
+	cb.Delete().After("gorm:delete").Register(name, afterStatement(StatementDelete))
+	cb.Row().After("gorm:row").Register(name, afterStatement(StatementSelect))
+	cb.Raw().After("gorm:raw").Register(name, afterStatement(StatementRaw))
+}
+
+// Returns the callback run after every statement of kind.
+func afterStatement(kind string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		traceStatement(db)
+		recordStatement(db)
+		observeStatement(db, kind)
+	}
+}
@@ -0,0 +1,102 @@
+package realorm
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrClosed = errors.New("orm is closed")
+)
+
+// Tracks the operations in flight on the databases of an orm and its copies.
+type gate struct {
+	mu     sync.Mutex
+	closed bool
+	active int
+	// Closed when the gate is closed and no operation is in flight
+	drained chan struct{}
+}
+
+func newGate() *gate {
+	return &gate{drained: make(chan struct{})}
+}
+
+// Admits an operation, unless the gate is closed.
+func (g *gate) enter() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return ErrClosed
+	}
+	g.active++
+	return nil
+}
+
+// Ends an operation admitted by enter.
+func (g *gate) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.active--
+	if g.closed && g.active == 0 {
+		close(g.drained)
+	}
+}
+
+// Stops admitting operations. It returns false if the gate was closed
+// already.
+func (g *gate) close() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return false
+	}
+
+	g.closed = true
+	if g.active == 0 {
+		close(g.drained)
+	}
+	return true
+}
+
+// Admits an operation on o. Operations inside another one, like the queries
+// of a transaction, are always admitted, so transactions can finish while
+// the orm is closing.
+func (o *orm) enter() error {
+	if o.gate == nil || o.inFlight {
+		return nil
+	}
+	return o.gate.enter()
+}
+
+// Ends an operation admitted by enter.
+func (o *orm) leave() {
+	if o.gate != nil && !o.inFlight {
+		o.gate.leave()
+	}
+}
+
+// Close stops accepting new operations, waits for the operations and
+// transactions in flight until ctx is done, then closes the connection pools
+// of the primary and the replicas. Operations started after Close, on this
+// ORM or any ORM derived from it, fail with ErrClosed.
+// It returns the error of ctx if operations were still in flight, and
+// ErrClosed if the ORM was closed already.
+func (o *orm) Close(ctx context.Context) error {
+	if o.gate != nil {
+		if !o.gate.close() {
+			return ErrClosed
+		}
+
+		select {
+		case <-o.gate.drained:
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), o.close())
+		}
+	}
+	return o.close()
+}
@@ -0,0 +1,125 @@
+package realorm_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func close_orm(t *testing.T) realorm.ORM {
+	orm := realorm.New(filepath.Join(t.TempDir(), "close.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+	return orm
+}
+
+// Starts a transaction creating a post that blocks until release is closed.
+func blocked_transaction(orm realorm.ORM, release chan struct{}) chan error {
+	started, done := make(chan struct{}), make(chan error, 1)
+	go func() {
+		done <- orm.Transaction(func(tx realorm.ORM) error {
+			close(started)
+			<-release
+			return tx.Create(&Post{ID: 1, Title: "Draining", Content: "Finished after Close"})
+		})
+	}()
+
+	<-started
+	return done
+}
+
+func Test_realorm_Close(t *testing.T) {
+	orm := close_orm(t)
+	derived := orm.WithQueryTimeout(time.Minute)
+
+	if err := orm.Close(context.Background()); err != nil {
+		t.Fatalf("error closing orm: %v\n", err)
+	}
+
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	if err := derived.Create(&Post{Title: "Hello", Content: "World"}); !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed on a derived orm, got %v", err)
+	}
+
+	err := orm.Transaction(func(tx realorm.ORM) error { return nil })
+	if !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	if err := orm.Migrate(&Post{}); !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	if health, err := orm.HealthCheck(context.Background()); !errors.Is(err, realorm.ErrClosed) || health.Status != realorm.StatusDown {
+		t.Errorf("expected ErrClosed and status down, got %v and %v", err, health.Status)
+	}
+
+	sqlDB, _ := orm.GetDB().DB()
+	if err := sqlDB.Ping(); err == nil {
+		t.Errorf("expected error pinging a closed database, got nil")
+	}
+
+	if err := orm.Close(context.Background()); !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed closing twice, got %v", err)
+	}
+}
+
+func Test_realorm_CloseDrains(t *testing.T) {
+	orm := close_orm(t)
+
+	release := make(chan struct{})
+	done := blocked_transaction(orm, release)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- orm.Close(context.Background())
+	}()
+
+	// new operations are rejected while the transaction is in flight
+	var posts []Post
+	for !errors.Is(orm.FindAll(&posts, nil), realorm.ErrClosed) {
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned before the transaction finished: %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("error finishing transaction: %v\n", err)
+	}
+
+	if err := <-closed; err != nil {
+		t.Errorf("error closing orm: %v\n", err)
+	}
+}
+
+func Test_realorm_CloseDeadline(t *testing.T) {
+	orm := close_orm(t)
+
+	release := make(chan struct{})
+	done := blocked_transaction(orm, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := orm.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// the transaction keeps its connection until it ends
+	close(release)
+	<-done
+}
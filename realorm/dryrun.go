@@ -0,0 +1,167 @@
+package realorm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Statement is a SQL statement with its bound arguments.
+type Statement struct {
+	SQL  string
+	Args []any
+	// SQL with the arguments inlined, for reading only
+	Explained string
+}
+
+// StatementRecorder records the statements of a dry-run ORM.
+type StatementRecorder struct {
+	mu         sync.Mutex
+	statements []Statement
+}
+
+// NewStatementRecorder returns an empty StatementRecorder.
+func NewStatementRecorder() *StatementRecorder {
+	return &StatementRecorder{}
+}
+
+// Statements returns the recorded statements in execution order.
+func (r *StatementRecorder) Statements() []Statement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Statement(nil), r.statements...)
+}
+
+// Reset forgets the recorded statements.
+func (r *StatementRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.statements = nil
+}
+
+// String returns the recorded statements one per line with their arguments,
+// suitable for golden files.
+func (r *StatementRecorder) String() string {
+	var b strings.Builder
+	for _, s := range r.Statements() {
+		fmt.Fprintf(&b, "%s -- %#v\n", s.SQL, s.Args)
+	}
+	return b.String()
+}
+
+func (r *StatementRecorder) record(s Statement) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.statements = append(r.statements, s)
+}
+
+type recorderKey struct{}
+
+// DryRun returns an ORM recording in r the statements it would execute
+// instead of executing them.
+// Reads find nothing, so statements that depend on their results are
+// recorded with nil arguments, like the keys of the parent rows of preloads.
+// Transactions are not started.
+func (o *orm) DryRun(r *StatementRecorder) ORM {
+	c := o.clone()
+	c.dryRun = true
+	c.replicas = nil
+	c.caching = false
+	c.ctx = context.WithValue(o.context(), recorderKey{}, r)
+	c.DB = o.DB.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true}).WithContext(c.ctx)
+	return c
+}
+
+// Runs fn in a transaction, unless the orm is a dry run.
+func (o *orm) inTransaction(fn func(tx *gorm.DB) error) error {
+	if o.dryRun {
+		return fn(o.DB)
+	}
+	return o.DB.Transaction(fn)
+}
+
+// Records the statement just built by db if it belongs to a dry run.
+func recordStatement(db *gorm.DB) {
+	if !db.DryRun || db.Statement.Context == nil {
+		return
+	}
+
+	r, ok := db.Statement.Context.Value(recorderKey{}).(*StatementRecorder)
+	if !ok {
+		return
+	}
+
+	sql := db.Statement.SQL.String()
+	if sql == "" {
+		return
+	}
+
+	args := append([]any(nil), db.Statement.Vars...)
+	r.record(Statement{SQL: sql, Args: args, Explained: db.Dialector.Explain(sql, args...)})
+
+	if len(db.Statement.Preloads) > 0 && db.Statement.Schema != nil {
+		recordPreloads(db, r)
+	}
+}
+
+// Records the queries that preload the associations of the statement of db.
+func recordPreloads(db *gorm.DB, r *StatementRecorder) {
+	relations := db.Statement.Schema.Relationships.Relations
+
+	var names []string
+	if _, ok := db.Statement.Preloads[clause.Associations]; ok {
+		for name := range relations {
+			names = append(names, name)
+		}
+	} else {
+		for name := range db.Statement.Preloads {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		rel, ok := relations[name]
+		if !ok {
+			continue
+		}
+
+		table, column := preloadColumn(rel)
+		if column == "" {
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db, Clauses: map[string]clause.Clause{}}
+		stmt.AddClause(clause.Select{})
+		stmt.AddClause(clause.From{Tables: []clause.Table{{Name: table}}})
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: []interface{}{nil}}}})
+		stmt.Build("SELECT", "FROM", "WHERE")
+
+		sql := stmt.SQL.String()
+		r.record(Statement{SQL: sql, Args: stmt.Vars, Explained: db.Dialector.Explain(sql, stmt.Vars...)})
+	}
+}
+
+// Returns the table and column a preload of rel filters on.
+func preloadColumn(rel *schema.Relationship) (string, string) {
+	for _, ref := range rel.References {
+		switch {
+		case rel.JoinTable != nil && ref.OwnPrimaryKey:
+			return rel.JoinTable.Table, ref.ForeignKey.DBName
+		case rel.JoinTable == nil && ref.OwnPrimaryKey:
+			return rel.FieldSchema.Table, ref.ForeignKey.DBName
+		case rel.JoinTable == nil && ref.PrimaryKey != nil:
+			return rel.FieldSchema.Table, ref.PrimaryKey.DBName
+		}
+	}
+	return "", ""
+}
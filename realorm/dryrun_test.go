@@ -0,0 +1,108 @@
+package realorm_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+type Author struct {
+	ID    uint   `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	Name  string `gorm:"type:varchar(100);not null"`
+	Books []Book
+}
+
+type Book struct {
+	ID       uint   `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	AuthorID uint   `gorm:"not null"`
+	Title    string `gorm:"type:varchar(100);not null"`
+}
+
+func Test_realorm_DryRun(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "dryrun.db"), testDialect)
+	if err := orm.Migrate(&Author{}, &Book{}); err != nil {
+		t.Fatalf("error migrating authors: %v\n", err)
+	}
+
+	recorder := realorm.NewStatementRecorder()
+	dry := orm.DryRun(recorder)
+
+	var authors []Author
+	where := &realorm.WhereClause{Query: "name = ?", Args: []interface{}{"Achebe"}}
+	if _, err := dry.FindAllPaginated(&authors, 2, 10, where); err != nil {
+		t.Fatalf("error paginating authors: %v\n", err)
+	}
+
+	expected := "SELECT count(*) FROM `authors` WHERE name = ? -- []interface {}{\"Achebe\"}\n" +
+		"SELECT * FROM `authors` WHERE name = ? LIMIT 10 OFFSET 10 -- []interface {}{\"Achebe\"}\n" +
+		"SELECT * FROM `books` WHERE `author_id` = ? -- []interface {}{interface {}(nil)}\n"
+
+	if recorder.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, recorder.String())
+	}
+
+	recorder.Reset()
+	if err := dry.Create(&Author{ID: 7, Name: "Achebe"}); err != nil {
+		t.Fatalf("error creating author: %v\n", err)
+	}
+
+	if _, err := dry.Update(Author{Name: "Chinua"}, 7, &realorm.WhereClause{Query: "id = ?", Args: []interface{}{7}}); err != nil {
+		t.Fatalf("error updating author: %v\n", err)
+	}
+
+	statements := recorder.Statements()
+	prefixes := []string{"INSERT INTO `authors`", "SELECT * FROM `authors`", "SELECT * FROM `books`", "SELECT * FROM `authors`", "UPDATE `authors` SET `name`=?", "SELECT * FROM `authors`", "SELECT * FROM `books`"}
+	if len(statements) != len(prefixes) {
+		t.Fatalf("expected %d statements, got:\n%s", len(prefixes), recorder.String())
+	}
+
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(statements[i].SQL, prefix) {
+			t.Errorf("expected statement %d to start with %s, got %s", i, prefix, statements[i].SQL)
+		}
+	}
+
+	if statements[0].Explained != "INSERT INTO `authors` (`name`,`id`) VALUES (\"Achebe\",7) RETURNING `id`" {
+		t.Errorf("unexpected explained insert: %s", statements[0].Explained)
+	}
+
+	// nothing reached the database
+	if err := orm.FindAll(&authors, nil); err != nil {
+		t.Fatalf("error finding authors: %v\n", err)
+	}
+
+	if len(authors) != 0 {
+		t.Errorf("expected 0 authors, got %d", len(authors))
+	}
+}
+
+// Run with -race: dry runs and plans of an ORM serving queries must not
+// modify its database.
+func Test_realorm_DryRunConcurrent(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "dryrun.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			var posts []Post
+			if err := orm.FindAll(&posts, nil); err != nil {
+				t.Errorf("error finding posts: %v\n", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		var posts []Post
+		if err := orm.DryRun(realorm.NewStatementRecorder()).FindAll(&posts, nil); err != nil {
+			t.Errorf("error in dry run: %v\n", err)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,18 @@
+package realorm
+
+import (
+	"github.com/abiiranathan/realorm/database"
+)
+
+// Errors of constraint violations, the same for every dialect. The error of
+// the driver stays in the chain, for errors.As.
+var (
+	ErrDuplicateKey        = database.ErrDuplicateKey
+	ErrForeignKeyViolation = database.ErrForeignKeyViolation
+)
+
+// Returns err wrapped in ErrDuplicateKey or ErrForeignKeyViolation by the
+// error translator of the dialect of the orm.
+func (o *orm) translateError(err error) error {
+	return database.TranslateError(o.dialect, err)
+}
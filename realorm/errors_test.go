@@ -0,0 +1,51 @@
+package realorm_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/abiiranathan/realorm/database"
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+type Comment struct {
+	ID     uint   `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	PostID uint   `gorm:"not null"`
+	Post   *Post  `gorm:"constraint:OnDelete:RESTRICT"`
+	Body   string `gorm:"type:varchar(100);not null"`
+}
+
+func Test_realorm_ConstraintErrors(t *testing.T) {
+	// both sqlite drivers, if this build has cgo
+	for _, dialect := range []database.DialectString{database.SQLITE, database.SQLITE_DEFAULT} {
+		config := &database.SQLiteConfig{
+			Path:        filepath.Join(t.TempDir(), "constraints.db"),
+			ForeignKeys: true,
+			PureGo:      dialect == database.SQLITE,
+		}
+
+		orm := realorm.New(config, dialect)
+		if err := orm.Migrate(&Post{}, &Comment{}); err != nil {
+			t.Fatalf("%s: error migrating: %v\n", dialect, err)
+		}
+
+		if err := orm.Create(&Post{ID: 1, Title: "Hello", Content: "World"}); err != nil {
+			t.Fatalf("%s: error creating post: %v\n", dialect, err)
+		}
+
+		err := orm.Create(&Post{ID: 1, Title: "Copy", Content: "World"})
+		if !errors.Is(err, realorm.ErrDuplicateKey) {
+			t.Errorf("%s: expected ErrDuplicateKey, got %v", dialect, err)
+		}
+
+		err = orm.Create(&Comment{PostID: 2, Body: "First"})
+		if !errors.Is(err, realorm.ErrForeignKeyViolation) {
+			t.Errorf("%s: expected ErrForeignKeyViolation, got %v", dialect, err)
+		}
+
+		if realorm.IsTransient(err) {
+			t.Errorf("%s: expected %v not to be transient", dialect, err)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package realorm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrExplainAnalyzeUnsupported = errors.New("explain analyze is not supported by sqlite")
+)
+
+// QueryPlan is the plan of a query as reported by the database.
+type QueryPlan struct {
+	// The query explained
+	Statement Statement
+	// Steps of the plan in the order reported by the database
+	Nodes []PlanNode
+	// Tables read with a full table scan
+	FullScans []string
+	// Plan as returned by the database
+	Raw string
+}
+
+// PlanNode is a step of a query plan.
+type PlanNode struct {
+	// Description of the step
+	Detail string
+	// Table read by the step, if any
+	Table string
+	// The step reads every row of Table
+	FullScan bool
+}
+
+// Explain returns the plans of the queries op would run.
+// op is run in a dry run, then every SELECT statement it recorded is
+// explained. If analyze is true, the queries are executed to report actual
+// timings on postgres and mysql.
+func (o *orm) Explain(op func(o ORM) error, analyze bool) (plans []QueryPlan, err error) {
+	err = o.run(OpExplain, nil, func(o *orm) error {
+		plans, err = o.explainAll(op, analyze)
+		return err
+	})
+	return plans, err
+}
+
+func (o *orm) explainAll(op func(o ORM) error, analyze bool) ([]QueryPlan, error) {
+	if analyze && o.DB.Dialector.Name() == "sqlite" {
+		return nil, ErrExplainAnalyzeUnsupported
+	}
+
+	recorder := NewStatementRecorder()
+	if err := op(o.DryRun(recorder)); err != nil {
+		return nil, err
+	}
+
+	plans := []QueryPlan{}
+	for _, stmt := range recorder.Statements() {
+		if !strings.HasPrefix(strings.ToUpper(stmt.SQL), "SELECT") {
+			continue
+		}
+
+		plan, err := o.explain(stmt, analyze)
+		if err != nil {
+			return nil, fmt.Errorf("explain %s: %w", stmt.SQL, err)
+		}
+		plans = append(plans, *plan)
+	}
+
+	return plans, nil
+}
+
+// Runs EXPLAIN for stmt and parses the plan.
+func (o *orm) explain(stmt Statement, analyze bool) (*QueryPlan, error) {
+	plan := &QueryPlan{Statement: stmt}
+
+	var query string
+	switch o.DB.Dialector.Name() {
+	case "postgres":
+		query = "EXPLAIN (FORMAT JSON) " + stmt.SQL
+		if analyze {
+			query = "EXPLAIN (ANALYZE, FORMAT JSON) " + stmt.SQL
+		}
+	case "mysql":
+		query = "EXPLAIN " + stmt.SQL
+		if analyze {
+			query = "EXPLAIN ANALYZE " + stmt.SQL
+		}
+	case "sqlite":
+		query = "EXPLAIN QUERY PLAN " + stmt.SQL
+	default:
+		return nil, fmt.Errorf("explain is not supported by %s", o.DB.Dialector.Name())
+	}
+
+	rows, err := o.DB.Statement.ConnPool.QueryContext(o.context(), query, stmt.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	table, err := scanTable(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case o.DB.Dialector.Name() == "postgres":
+		err = parsePostgresPlan(plan, table)
+	case o.DB.Dialector.Name() == "mysql" && analyze:
+		parseMySQLAnalyze(plan, table)
+	case o.DB.Dialector.Name() == "mysql":
+		parseMySQLPlan(plan, table)
+	default:
+		parseSQLitePlan(plan, table)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range plan.Nodes {
+		if node.FullScan {
+			plan.FullScans = append(plan.FullScans, node.Table)
+		}
+	}
+	return plan, nil
+}
+
+// Reads all rows as strings keyed by column name.
+func scanTable(rows *sql.Rows) ([]map[string]string, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	table := []map[string]string{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := map[string]string{}
+		for i, column := range columns {
+			row[column] = values[i].String
+		}
+		table = append(table, row)
+	}
+
+	return table, rows.Err()
+}
+
+// Parses the output of EXPLAIN QUERY PLAN. Steps read like
+// "SCAN posts" or "SEARCH posts USING INTEGER PRIMARY KEY (rowid=?)".
+func parseSQLitePlan(plan *QueryPlan, table []map[string]string) {
+	var raw []string
+
+	for _, row := range table {
+		detail := row["detail"]
+		raw = append(raw, detail)
+
+		node := PlanNode{Detail: detail}
+		fields := strings.Fields(detail)
+
+		if len(fields) > 1 && (fields[0] == "SCAN" || fields[0] == "SEARCH") {
+			node.Table = fields[1]
+			if node.Table == "TABLE" && len(fields) > 2 {
+				node.Table = fields[2]
+			}
+
+			node.FullScan = fields[0] == "SCAN" && !strings.Contains(detail, "INDEX")
+		}
+
+		plan.Nodes = append(plan.Nodes, node)
+	}
+
+	plan.Raw = strings.Join(raw, "\n")
+}
+
+// Parses the output of EXPLAIN on mysql. A type of ALL is a full scan.
+func parseMySQLPlan(plan *QueryPlan, table []map[string]string) {
+	var raw []string
+
+	for _, row := range table {
+		node := PlanNode{
+			Table:    row["table"],
+			FullScan: row["type"] == "ALL",
+			Detail:   fmt.Sprintf("%s on %s using %s", row["type"], row["table"], row["key"]),
+		}
+
+		raw = append(raw, node.Detail)
+		plan.Nodes = append(plan.Nodes, node)
+	}
+
+	plan.Raw = strings.Join(raw, "\n")
+}
+
+// Parses the tree returned by EXPLAIN ANALYZE on mysql. Full scans read like
+// "-> Table scan on posts  (cost=...)".
+func parseMySQLAnalyze(plan *QueryPlan, table []map[string]string) {
+	for _, row := range table {
+		for _, v := range row {
+			plan.Raw += v
+		}
+	}
+
+	for _, line := range strings.Split(plan.Raw, "\n") {
+		detail := strings.TrimLeft(strings.TrimSpace(line), "-> ")
+		if detail == "" {
+			continue
+		}
+
+		node := PlanNode{Detail: detail}
+		if i := strings.Index(detail, " on "); i >= 0 {
+			if fields := strings.Fields(detail[i+4:]); len(fields) > 0 {
+				node.Table = fields[0]
+			}
+		}
+
+		node.FullScan = strings.HasPrefix(detail, "Table scan on ")
+		plan.Nodes = append(plan.Nodes, node)
+	}
+}
+
+type postgresNode struct {
+	NodeType     string         `json:"Node Type"`
+	RelationName string         `json:"Relation Name"`
+	IndexName    string         `json:"Index Name"`
+	Plans        []postgresNode `json:"Plans"`
+}
+
+// Parses the output of EXPLAIN (FORMAT JSON). A Seq Scan is a full scan.
+func parsePostgresPlan(plan *QueryPlan, table []map[string]string) error {
+	if len(table) == 0 {
+		return errors.New("empty plan")
+	}
+
+	for _, v := range table[0] {
+		plan.Raw = v
+	}
+
+	var explained []struct {
+		Plan postgresNode `json:"Plan"`
+	}
+
+	if err := json.Unmarshal([]byte(plan.Raw), &explained); err != nil {
+		return err
+	}
+
+	var walk func(n postgresNode)
+	walk = func(n postgresNode) {
+		detail := n.NodeType
+		if n.RelationName != "" {
+			detail += " on " + n.RelationName
+		}
+
+		if n.IndexName != "" {
+			detail += " using " + n.IndexName
+		}
+
+		plan.Nodes = append(plan.Nodes, PlanNode{
+			Detail:   detail,
+			Table:    n.RelationName,
+			FullScan: n.NodeType == "Seq Scan",
+		})
+
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+
+	for _, e := range explained {
+		walk(e.Plan)
+	}
+	return nil
+}
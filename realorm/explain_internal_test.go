@@ -0,0 +1,46 @@
+package realorm
+
+import "testing"
+
+func TestParsePostgresPlan(t *testing.T) {
+	plan := &QueryPlan{}
+	err := parsePostgresPlan(plan, []map[string]string{{
+		"QUERY PLAN": `[{"Plan": {"Node Type": "Limit", "Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "posts"},
+			{"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_pkey"}
+		]}}]`,
+	}})
+
+	if err != nil {
+		t.Fatalf("error parsing plan: %v\n", err)
+	}
+
+	if len(plan.Nodes) != 3 || !plan.Nodes[1].FullScan || plan.Nodes[2].FullScan {
+		t.Errorf("unexpected nodes: %+v", plan.Nodes)
+	}
+
+	if plan.Nodes[2].Detail != "Index Scan on users using users_pkey" {
+		t.Errorf("unexpected detail: %s", plan.Nodes[2].Detail)
+	}
+}
+
+func TestParseMySQLPlan(t *testing.T) {
+	plan := &QueryPlan{}
+	parseMySQLPlan(plan, []map[string]string{
+		{"table": "posts", "type": "ALL", "key": ""},
+		{"table": "users", "type": "eq_ref", "key": "PRIMARY"},
+	})
+
+	if len(plan.Nodes) != 2 || !plan.Nodes[0].FullScan || plan.Nodes[1].FullScan {
+		t.Errorf("unexpected nodes: %+v", plan.Nodes)
+	}
+
+	plan = &QueryPlan{}
+	parseMySQLAnalyze(plan, []map[string]string{{
+		"EXPLAIN": "-> Limit: 10 row(s)\n    -> Table scan on posts  (cost=0.35 rows=1) (actual time=0.01..0.01 rows=0 loops=1)\n",
+	}})
+
+	if len(plan.Nodes) != 2 || !plan.Nodes[1].FullScan || plan.Nodes[1].Table != "posts" {
+		t.Errorf("unexpected nodes: %+v", plan.Nodes)
+	}
+}
@@ -0,0 +1,86 @@
+package realorm_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_Explain(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "explain.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	var posts []Post
+	plans, err := orm.Explain(func(o realorm.ORM) error {
+		_, err := o.FindAllPaginated(&posts, 1, 10, &realorm.WhereClause{
+			Query: "title = ?",
+			Args:  []interface{}{"Hello World"},
+		})
+		return err
+	}, false)
+
+	if err != nil {
+		t.Fatalf("error explaining query: %v\n", err)
+	}
+
+	// count and select
+	if len(plans) != 2 {
+		t.Fatalf("expected 2 plans, got %d", len(plans))
+	}
+
+	for _, plan := range plans {
+		if len(plan.FullScans) != 1 || plan.FullScans[0] != "posts" {
+			t.Errorf("expected a full scan of posts, got %+v", plan)
+		}
+	}
+
+	plans, err = orm.Explain(func(o realorm.ORM) error {
+		return o.Find(&Post{}, &realorm.WhereClause{Query: "id = ?", Args: []interface{}{1}})
+	}, false)
+
+	if err != nil {
+		t.Fatalf("error explaining query: %v\n", err)
+	}
+
+	if len(plans) != 1 || len(plans[0].FullScans) != 0 || plans[0].Nodes[0].Table != "posts" {
+		t.Errorf("expected a primary key search on posts, got %+v", plans)
+	}
+
+	_, err = orm.Explain(func(o realorm.ORM) error { return o.FindAll(&posts, nil) }, true)
+	if err != realorm.ErrExplainAnalyzeUnsupported {
+		t.Errorf("expected ErrExplainAnalyzeUnsupported, got %v", err)
+	}
+}
+
+func Test_realorm_ExplainOperation(t *testing.T) {
+	recorder := realorm.NewSpanRecorder()
+	orm := realorm.New(filepath.Join(t.TempDir(), "explain.db"), testDialect).WithTracer(recorder)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	var posts []Post
+	explain := func(o realorm.ORM) error { return o.FindAll(&posts, nil) }
+	if _, err := orm.Explain(explain, false); err != nil {
+		t.Fatalf("error explaining query: %v\n", err)
+	}
+
+	// the dry run is part of the explain operation
+	spans := recorder.Spans()
+	if len(spans) != 2 || spans[0].Name != "realorm.explain" || spans[1].ParentID != spans[0].ID {
+		t.Errorf("expected an explain span with a child, got %+v", spans)
+	}
+
+	if err := orm.Close(context.Background()); err != nil {
+		t.Fatalf("error closing orm: %v\n", err)
+	}
+
+	if _, err := orm.Explain(explain, false); !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
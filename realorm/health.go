@@ -0,0 +1,239 @@
+package realorm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuses reported by HealthCheck
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Timeout of HealthCheck when its context has no deadline.
+var HealthCheckTimeout = 5 * time.Second
+
+// Health is the state of the database reported by HealthCheck.
+type Health struct {
+	Status        string            `json:"status"`
+	Dialect       string            `json:"dialect"`
+	ServerVersion string            `json:"server_version,omitempty"`
+	Latency       time.Duration     `json:"latency_ns"`
+	Pool          PoolStats         `json:"pool"`
+	Migrations    []MigrationStatus `json:"migrations"`
+	Error         string            `json:"error,omitempty"`
+}
+
+// PoolStats are the statistics of a connection pool.
+type PoolStats struct {
+	MaxOpen      int           `json:"max_open"`
+	Open         int           `json:"open"`
+	InUse        int           `json:"in_use"`
+	Idle         int           `json:"idle"`
+	WaitCount    int64         `json:"wait_count"`
+	WaitDuration time.Duration `json:"wait_duration_ns"`
+}
+
+// MigrationStatus tells whether the table of a model passed to Migrate
+// exists with all its columns.
+type MigrationStatus struct {
+	Table          string   `json:"table"`
+	Migrated       bool     `json:"migrated"`
+	MissingColumns []string `json:"missing_columns,omitempty"`
+}
+
+// Ready returns true if the database is up and every model is migrated.
+func (h *Health) Ready() bool {
+	if h.Status != StatusUp {
+		return false
+	}
+
+	for _, m := range h.Migrations {
+		if !m.Migrated {
+			return false
+		}
+	}
+	return true
+}
+
+// Models passed to Migrate, shared by the copies of an orm.
+type migrations struct {
+	mu     sync.Mutex
+	models []any
+	// Struct types of models, so migrating a model again does not add it twice
+	types map[reflect.Type]bool
+}
+
+func (m *migrations) add(models ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.types == nil {
+		m.types = map[reflect.Type]bool{}
+	}
+
+	for _, model := range models {
+		t := reflect.TypeOf(model)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if !m.types[t] {
+			m.types[t] = true
+			m.models = append(m.models, model)
+		}
+	}
+}
+
+func (m *migrations) list() []any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]any(nil), m.models...)
+}
+
+// HealthCheck pings the primary database and reports its pool statistics,
+// server version and the status of the models migrated by this ORM,
+// including the history tables of historical models.
+// The ping is bounded by HealthCheckTimeout unless ctx has a deadline.
+// The returned error is the ping error, in which case the status is down.
+func (o *orm) HealthCheck(ctx context.Context) (*Health, error) {
+	if err := o.enter(); err != nil {
+		return &Health{Status: StatusDown, Error: err.Error(), Migrations: []MigrationStatus{}}, err
+	}
+	defer o.leave()
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, HealthCheckTimeout)
+		defer cancel()
+	}
+
+	health := &Health{
+		Status:     StatusUp,
+		Dialect:    o.DB.Dialector.Name(),
+		Migrations: []MigrationStatus{},
+	}
+
+	sqlDB, err := o.DB.DB()
+	if err == nil {
+		start := time.Now()
+		err = sqlDB.PingContext(ctx)
+		health.Latency = time.Since(start)
+
+		stats := sqlDB.Stats()
+		health.Pool = PoolStats{
+			MaxOpen:      stats.MaxOpenConnections,
+			Open:         stats.OpenConnections,
+			InUse:        stats.InUse,
+			Idle:         stats.Idle,
+			WaitCount:    stats.WaitCount,
+			WaitDuration: stats.WaitDuration,
+		}
+	}
+
+	if err != nil {
+		health.Status = StatusDown
+		health.Error = err.Error()
+		return health, err
+	}
+
+	db := o.DB.Session(&gorm.Session{NewDB: true}).WithContext(ctx)
+	health.ServerVersion = serverVersion(db)
+
+	if o.migrations != nil {
+		for _, model := range o.migrations.list() {
+			health.Migrations = append(health.Migrations, migrationStatus(db, model))
+
+			if !isHistorical(model) {
+				continue
+			}
+
+			if h, err := parseHistory(db, model); err == nil {
+				history := migrationStatus(db.Table(h.table), reflect.New(h.rtype).Interface())
+				health.Migrations = append(health.Migrations, history)
+			}
+		}
+	}
+
+	return health, nil
+}
+
+// Returns the version of the database server or an empty string if it
+// cannot be queried.
+func serverVersion(db *gorm.DB) string {
+	var query string
+	switch db.Dialector.Name() {
+	case "postgres":
+		query = "SHOW server_version"
+	case "mysql":
+		query = "SELECT VERSION()"
+	case "sqlite":
+		query = "SELECT sqlite_version()"
+	case "sqlserver":
+		query = "SELECT @@VERSION"
+	default:
+		return ""
+	}
+
+	var version string
+	if err := db.Raw(query).Row().Scan(&version); err != nil {
+		return ""
+	}
+	return version
+}
+
+// Returns whether the table and columns of model exist. The table is the one
+// set on db with Table, if any.
+func migrationStatus(db *gorm.DB, model any) MigrationStatus {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return MigrationStatus{}
+	}
+
+	if db.Statement.Table != "" {
+		stmt.Table = db.Statement.Table
+	}
+
+	status := MigrationStatus{Table: stmt.Table}
+	migrator := db.Migrator()
+	if !migrator.HasTable(model) {
+		return status
+	}
+
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName != "" && !migrator.HasColumn(model, field.DBName) {
+			status.MissingColumns = append(status.MissingColumns, field.DBName)
+		}
+	}
+
+	status.Migrated = len(status.MissingColumns) == 0
+	return status
+}
+
+// HealthHandler returns an http.Handler serving the HealthCheck of o as
+// JSON, for liveness and readiness probes.
+// It responds with 503 Service Unavailable if the database is down or,
+// when ready is true, if any model is not migrated.
+func HealthHandler(o ORM, ready bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health, _ := o.HealthCheck(r.Context())
+
+		status := http.StatusOK
+		if health.Status != StatusUp || (ready && !health.Ready()) {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(health)
+	})
+}
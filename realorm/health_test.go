@@ -0,0 +1,111 @@
+package realorm_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_HealthCheck(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "health.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	health, err := orm.HealthCheck(context.Background())
+	if err != nil {
+		t.Fatalf("error checking health: %v\n", err)
+	}
+
+	if health.Status != realorm.StatusUp || health.Dialect != "sqlite" || health.ServerVersion == "" {
+		t.Errorf("unexpected health: %+v", health)
+	}
+
+	if len(health.Migrations) != 1 || health.Migrations[0].Table != "posts" || !health.Migrations[0].Migrated {
+		t.Errorf("expected posts to be migrated, got %+v", health.Migrations)
+	}
+
+	get := func(ready bool) (int, realorm.Health) {
+		rec := httptest.NewRecorder()
+		realorm.HealthHandler(orm, ready).ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+
+		var h realorm.Health
+		if err := json.NewDecoder(rec.Body).Decode(&h); err != nil {
+			t.Fatalf("error decoding health: %v\n", err)
+		}
+		return rec.Code, h
+	}
+
+	if code, _ := get(true); code != http.StatusOK {
+		t.Errorf("expected ready, got %d", code)
+	}
+
+	// alive but not ready
+	if err := orm.GetDB().Migrator().DropTable(&Post{}); err != nil {
+		t.Fatalf("error dropping posts: %v\n", err)
+	}
+
+	if code, _ := get(false); code != http.StatusOK {
+		t.Errorf("expected alive, got %d", code)
+	}
+
+	code, h := get(true)
+	if code != http.StatusServiceUnavailable || h.Migrations[0].Migrated {
+		t.Errorf("expected not ready, got %d %+v", code, h)
+	}
+
+	// down
+	sqlDB, _ := orm.GetDB().DB()
+	sqlDB.Close()
+
+	if _, err := orm.HealthCheck(context.Background()); err == nil {
+		t.Errorf("expected error checking health of a closed database")
+	}
+
+	code, h = get(false)
+	if code != http.StatusServiceUnavailable || h.Status != realorm.StatusDown || h.Error == "" {
+		t.Errorf("expected down, got %d %+v", code, h)
+	}
+}
+
+func Test_realorm_HealthCheckMigrations(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "health.db"), testDialect)
+
+	// models migrated again are checked once, with their history tables
+	for i := 0; i < 2; i++ {
+		if err := orm.Migrate(&Post{}, &Article{}); err != nil {
+			t.Fatalf("error migrating: %v\n", err)
+		}
+	}
+
+	health, err := orm.HealthCheck(context.Background())
+	if err != nil {
+		t.Fatalf("error checking health: %v\n", err)
+	}
+
+	var tables []string
+	for _, m := range health.Migrations {
+		if !m.Migrated {
+			t.Errorf("expected %s to be migrated, got %+v", m.Table, m)
+		}
+		tables = append(tables, m.Table)
+	}
+
+	if !reflect.DeepEqual(tables, []string{"posts", "articles", "articles_history"}) {
+		t.Errorf("unexpected tables %v", tables)
+	}
+
+	if err := orm.GetDB().Migrator().DropTable("articles_history"); err != nil {
+		t.Fatalf("error dropping history: %v\n", err)
+	}
+
+	if health, _ := orm.HealthCheck(context.Background()); health.Ready() {
+		t.Errorf("expected not ready without the history table")
+	}
+}
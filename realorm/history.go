@@ -0,0 +1,345 @@
+package realorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Operations recorded in the history_operation column of a shadow table.
+// A CREATE row marks the time an entity was created, its columns are those
+// of the created entity. History does not list it.
+const (
+	HistoryCreate = "CREATE"
+	HistoryUpdate = "UPDATE"
+	HistoryDelete = "DELETE"
+)
+
+var (
+	ErrNotHistorical = errors.New("model does not keep history")
+)
+
+// Historical is implemented by models that opt in to temporal history.
+// Before every Update or Delete through the ORM, the rows about to change are
+// copied into a <table>_history shadow table created by Migrate.
+type Historical interface {
+	KeepHistory() bool
+}
+
+// HistoryEntry is a single archived version of an entity.
+type HistoryEntry struct {
+	// Pointer to a model holding the archived version
+	Entity any `json:"entity"`
+	// Operation that replaced this version (UPDATE or DELETE)
+	Operation string `json:"operation"`
+	// Time this version became current. Zero for the first version of an
+	// entity whose creation was not recorded, like rows created outside the ORM.
+	ValidFrom time.Time `json:"valid_from"`
+	// Time this version was replaced
+	ValidTo time.Time `json:"valid_to"`
+}
+
+// historySchema describes the shadow table of a historical model.
+type historySchema struct {
+	table string
+	model *schema.Schema
+	rtype reflect.Type
+}
+
+var historyTypes sync.Map
+
+// Returns true if model opts in to history.
+func isHistorical(model any) bool {
+	h, ok := model.(Historical)
+	return ok && h.KeepHistory()
+}
+
+// Parses model and builds the struct type of its shadow table.
+// The shadow table has all columns of the model, without their constraints,
+// plus history_id, history_valid_to and history_operation.
+func parseHistory(db *gorm.DB, model any) (*historySchema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("cannot keep history for %s: no primary key", stmt.Schema.Name)
+	}
+
+	table := stmt.Table + "_history"
+	if h, ok := historyTypes.Load(table); ok {
+		return h.(*historySchema), nil
+	}
+
+	fields := []reflect.StructField{
+		{
+			Name: "HistoryID",
+			Type: reflect.TypeOf(uint(0)),
+			Tag:  `gorm:"column:history_id;primaryKey;autoIncrement"`,
+		},
+	}
+
+	for _, name := range stmt.Schema.DBNames {
+		field := stmt.Schema.FieldsByDBName[name]
+
+		tags := []string{"column:" + name}
+		if t, ok := field.TagSettings["TYPE"]; ok {
+			tags = append(tags, "type:"+t)
+		}
+
+		if s, ok := field.TagSettings["SIZE"]; ok {
+			tags = append(tags, "size:"+s)
+		}
+
+		if field == stmt.Schema.PrioritizedPrimaryField {
+			tags = append(tags, "index")
+		}
+
+		fields = append(fields, reflect.StructField{
+			Name: field.Name,
+			Type: field.FieldType,
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"%s"`, strings.Join(tags, ";"))),
+		})
+	}
+
+	fields = append(fields,
+		reflect.StructField{
+			Name: "HistoryValidTo",
+			Type: reflect.TypeOf(time.Time{}),
+			Tag:  `gorm:"column:history_valid_to;not null;index"`,
+		},
+		reflect.StructField{
+			Name: "HistoryOperation",
+			Type: reflect.TypeOf(""),
+			Tag:  `gorm:"column:history_operation;size:16;not null"`,
+		},
+	)
+
+	h := &historySchema{
+		table: table,
+		model: stmt.Schema,
+		rtype: reflect.StructOf(fields),
+	}
+
+	historyTypes.Store(table, h)
+	return h, nil
+}
+
+// Name of the primary key column of the model.
+func (h *historySchema) primaryKey() string {
+	return h.model.PrioritizedPrimaryField.DBName
+}
+
+// Copies the columns of model, a pointer to a struct, into a new shadow row.
+func (h *historySchema) toHistory(model reflect.Value, validTo time.Time, operation string) any {
+	ctx := context.Background()
+	row := reflect.New(h.rtype)
+
+	for _, name := range h.model.DBNames {
+		field := h.model.FieldsByDBName[name]
+		if value, _ := field.ValueOf(ctx, model); value != nil {
+			row.Elem().FieldByName(field.Name).Set(reflect.ValueOf(value))
+		}
+	}
+
+	row.Elem().FieldByName("HistoryValidTo").Set(reflect.ValueOf(validTo))
+	row.Elem().FieldByName("HistoryOperation").SetString(operation)
+	return row.Interface()
+}
+
+// Copies the columns of a shadow row into a new model.
+func (h *historySchema) fromHistory(row reflect.Value, model reflect.Value) error {
+	ctx := context.Background()
+
+	for _, name := range h.model.DBNames {
+		field := h.model.FieldsByDBName[name]
+		if err := field.Set(ctx, model, row.FieldByName(field.Name).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Archives the rows of model matched by where into the shadow table and
+// returns their number. If the primary key of model is set, only that row is
+// archived.
+func archive(tx *gorm.DB, model any, where *WhereClause, operation string, scope func(*gorm.DB) *gorm.DB) (int, error) {
+	h, err := parseHistory(tx, model)
+	if err != nil {
+		return 0, err
+	}
+
+	query := tx.Scopes(scope).Model(model).Where(where.Query, where.Args...)
+	if pk, zero := h.model.PrioritizedPrimaryField.ValueOf(context.Background(), reflect.ValueOf(model)); !zero {
+		query = query.Where(h.primaryKey()+" = ?", pk)
+	}
+
+	rows := reflect.New(reflect.SliceOf(h.model.ModelType))
+	if err := query.Find(rows.Interface()).Error; err != nil {
+		return 0, err
+	}
+
+	now := tx.NowFunc()
+	for i := 0; i < rows.Elem().Len(); i++ {
+		row := h.toHistory(rows.Elem().Index(i), now, operation)
+		if err := tx.Table(h.table).Create(row).Error; err != nil {
+			return 0, err
+		}
+	}
+	return rows.Elem().Len(), nil
+}
+
+// Records the creation of model, a pointer to a struct just inserted, in the
+// shadow table.
+func recordCreate(tx *gorm.DB, model any) error {
+	h, err := parseHistory(tx, model)
+	if err != nil {
+		return err
+	}
+
+	row := h.toHistory(reflect.ValueOf(model), tx.NowFunc(), HistoryCreate)
+	return tx.Table(h.table).Create(row).Error
+}
+
+// Creates the shadow tables of historical models.
+func migrateHistory(db *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if !isHistorical(model) {
+			continue
+		}
+
+		h, err := parseHistory(db, model)
+		if err != nil {
+			return err
+		}
+
+		if err := db.Table(h.table).AutoMigrate(reflect.New(h.rtype).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindAsOf loads into model the version of the entity with primary key key
+// that was current at time at. It fails with gorm.ErrRecordNotFound if the
+// entity did not exist at that time.
+func (o *orm) FindAsOf(model any, key any, at time.Time) error {
+	return o.run(OpFindAsOf, model, func(o *orm) error {
+		return o.findAsOf(model, key, at)
+	})
+}
+
+func (o *orm) findAsOf(model any, key any, at time.Time) error {
+	if !isHistorical(model) {
+		return ErrNotHistorical
+	}
+
+	h, err := parseHistory(o.DB, model)
+	if err != nil {
+		return err
+	}
+
+	tenant, err := o.tenantScope(model)
+	if err != nil {
+		return err
+	}
+
+	// The earliest version replaced after at was current at that time
+	row := reflect.New(h.rtype)
+	result := o.DB.Scopes(tenant).Unscoped().Table(h.table).
+		Where(h.primaryKey()+" = ? AND history_valid_to > ?", key, at).
+		Order("history_valid_to, history_id").
+		Limit(1).
+		Find(row.Interface())
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		// Not replaced since, so the current row is the version at that time
+		return o.DB.Scopes(tenant).Where(h.primaryKey()+" = ?", key).First(model).Error
+	}
+
+	if row.Elem().FieldByName("HistoryOperation").String() == HistoryCreate {
+		// created after at
+		return gorm.ErrRecordNotFound
+	}
+
+	return h.fromHistory(row.Elem(), reflect.ValueOf(model))
+}
+
+// History returns the archived versions of the entity with primary key key,
+// oldest first. The current version is not included.
+// model is a pointer to a struct of the entity type.
+func (o *orm) History(model any, key any) (entries []HistoryEntry, err error) {
+	err = o.run(OpHistory, model, func(o *orm) error {
+		entries, err = o.history(model, key)
+		return err
+	})
+	return entries, err
+}
+
+func (o *orm) history(model any, key any) ([]HistoryEntry, error) {
+	if !isHistorical(model) {
+		return nil, ErrNotHistorical
+	}
+
+	h, err := parseHistory(o.DB, model)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant, err := o.tenantScope(model)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := reflect.New(reflect.SliceOf(h.rtype))
+	err = o.DB.Scopes(tenant).Unscoped().Table(h.table).
+		Where(h.primaryKey()+" = ?", key).
+		Order("history_valid_to, history_id").
+		Find(rows.Interface()).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []HistoryEntry{}
+	var validFrom time.Time
+
+	for i := 0; i < rows.Elem().Len(); i++ {
+		row := rows.Elem().Index(i)
+		operation := row.FieldByName("HistoryOperation").String()
+		validTo := row.FieldByName("HistoryValidTo").Interface().(time.Time)
+
+		// the next version starts at its creation
+		if operation == HistoryCreate {
+			validFrom = validTo
+			continue
+		}
+
+		entity := reflect.New(h.model.ModelType)
+		if err := h.fromHistory(row, entity); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, HistoryEntry{
+			Entity:    entity.Interface(),
+			Operation: operation,
+			ValidFrom: validFrom,
+			ValidTo:   validTo,
+		})
+		validFrom = validTo
+	}
+
+	return entries, nil
+}
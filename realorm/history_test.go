@@ -0,0 +1,112 @@
+package realorm_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/realorm/database"
+	"github.com/abiiranathan/realorm/realorm"
+	"gorm.io/gorm"
+)
+
+type Article struct {
+	ID    uint   `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	Title string `gorm:"type:varchar(100);not null"`
+}
+
+func (a *Article) KeepHistory() bool {
+	return true
+}
+
+func Test_realorm_History(t *testing.T) {
+	orm := realorm.New(database.SQLITE3_MEMORY_DB, testDialect)
+	if err := orm.Migrate(&Article{}); err != nil {
+		t.Fatalf("error migrating articles: %v\n", err)
+	}
+
+	defer orm.GetDB().Exec("DELETE FROM articles_history;")
+	defer orm.GetDB().Exec("DELETE FROM articles;")
+
+	// versions are ordered by the clock of the database
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated, deleted := created.Add(time.Hour), created.Add(2*time.Hour)
+
+	now := created
+	orm.GetDB().Config.NowFunc = func() time.Time { return now }
+
+	article := &Article{ID: UniqueID(), Title: "First"}
+	if err := orm.Create(article); err != nil {
+		t.Fatalf("error creating article: %v\n", err)
+	}
+
+	where := &realorm.WhereClause{Query: "id = ?", Args: []interface{}{article.ID}}
+
+	now = updated
+	if _, err := orm.Update(Article{Title: "Second"}, article.ID, where); err != nil {
+		t.Fatalf("error updating article: %v\n", err)
+	}
+
+	now = deleted
+	if err := orm.Delete(&Article{}, where); err != nil {
+		t.Fatalf("error deleting article: %v\n", err)
+	}
+
+	// the article did not exist yet
+	if err := orm.FindAsOf(&Article{}, article.ID, created.Add(-time.Minute)); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected gorm.ErrRecordNotFound before creation, got %v", err)
+	}
+
+	var old Article
+	if err := orm.FindAsOf(&old, article.ID, created); err != nil {
+		t.Fatalf("error finding article as of %v: %v\n", created, err)
+	}
+
+	if old.Title != "First" {
+		t.Errorf("expected First, got %s", old.Title)
+	}
+
+	var current Article
+	if err := orm.FindAsOf(&current, article.ID, deleted.Add(-time.Minute)); err != nil {
+		t.Fatalf("error finding article as of %v: %v\n", deleted, err)
+	}
+
+	if current.Title != "Second" {
+		t.Errorf("expected Second, got %s", current.Title)
+	}
+
+	// the article no longer exists
+	if err := orm.FindAsOf(&Article{}, article.ID, deleted); err == nil {
+		t.Errorf("expected error finding deleted article, got nil")
+	}
+
+	history, err := orm.History(&Article{}, article.ID)
+	if err != nil {
+		t.Fatalf("error getting history: %v\n", err)
+	}
+
+	if len(history) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(history))
+	}
+
+	if history[0].Operation != realorm.HistoryUpdate || history[1].Operation != realorm.HistoryDelete {
+		t.Errorf("unexpected operations: %s, %s", history[0].Operation, history[1].Operation)
+	}
+
+	if history[1].Entity.(*Article).Title != "Second" {
+		t.Errorf("expected Second, got %s", history[1].Entity.(*Article).Title)
+	}
+
+	if !history[0].ValidFrom.Equal(created) || !history[0].ValidTo.Equal(updated) {
+		t.Errorf("expected first version from %v to %v, got %v to %v", created, updated, history[0].ValidFrom, history[0].ValidTo)
+	}
+
+	if !history[1].ValidFrom.Equal(history[0].ValidTo) {
+		t.Errorf("versions are not contiguous: %v != %v", history[1].ValidFrom, history[0].ValidTo)
+	}
+
+	// models without history are rejected
+	if _, err := orm.History(&Post{}, article.ID); err != realorm.ErrNotHistorical {
+		t.Errorf("expected ErrNotHistorical, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package realorm
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// WithLogger returns an ORM logging its queries with l, for example a
+// database.SlogLogger.
+func (o *orm) WithLogger(l logger.Interface) ORM {
+	c := o.clone()
+	c.logger = l
+	c.DB = o.DB.Session(&gorm.Session{Logger: l})
+	return c
+}
+
+// WithLogLevel returns an ORM logging its queries at level.
+func (o *orm) WithLogLevel(level logger.LogLevel) ORM {
+	return o.WithLogger(o.DB.Logger.LogMode(level))
+}
@@ -0,0 +1,42 @@
+package realorm_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/realorm/database"
+	"github.com/abiiranathan/realorm/realorm"
+	"gorm.io/gorm/logger"
+)
+
+func Test_realorm_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	l := database.NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil)), testDialect, database.SlogConfig{})
+
+	orm, err := create_orm()
+	if err != nil {
+		t.Fatalf("error creating database: %v\n", err)
+	}
+
+	where := &realorm.WhereClause{Query: "title = ?", Args: []interface{}{"private"}}
+
+	// warn level does not log successful queries
+	var posts []Post
+	if err := orm.WithLogger(l).FindAll(&posts, where); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %s", buf.String())
+	}
+
+	if err := orm.WithLogger(l).WithLogLevel(logger.Info).FindAll(&posts, where); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if !strings.Contains(buf.String(), "msg=query") || strings.Contains(buf.String(), "private") {
+		t.Errorf("expected a redacted query record, got %s", buf.String())
+	}
+}
@@ -0,0 +1,364 @@
+package realorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Outcomes of an operation reported to metrics
+const (
+	OutcomeSuccess  = "success"
+	OutcomeNotFound = "not_found"
+	OutcomeError    = "error"
+)
+
+// Metrics records ORM operations and connection pool statistics.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveQuery records an operation on table that took duration, from
+	// the call of the ORM method to its return, across all its statements
+	// and retries
+	ObserveQuery(operation, table, outcome string, duration time.Duration)
+	// RegisterPool registers a connection pool whose statistics are collected
+	RegisterPool(name string, db *sql.DB)
+}
+
+// StatementMetrics is implemented by Metrics that also record every
+// statement of an operation on its own, like the history archive, the
+// write and the refetch of an Update.
+type StatementMetrics interface {
+	// ObserveStatement records a statement of kind select, insert, update,
+	// delete or raw on table, run by operation
+	ObserveStatement(operation, table, kind, outcome string, duration time.Duration)
+}
+
+// Kinds of statements reported to StatementMetrics
+const (
+	StatementSelect = "select"
+	StatementInsert = "insert"
+	StatementUpdate = "update"
+	StatementDelete = "delete"
+	StatementRaw    = "raw"
+)
+
+// WithMetrics returns an ORM recording its operations in m, and their
+// statements if m implements StatementMetrics.
+// The connection pools of the primary and replicas are registered with m as
+// "primary" and "replica-<n>".
+func (o *orm) WithMetrics(m Metrics) ORM {
+	c := o.clone()
+	c.metrics = m
+
+	if sqlDB, err := o.DB.DB(); err == nil {
+		m.RegisterPool("primary", sqlDB)
+	}
+
+	if o.replicas != nil {
+		for i, r := range o.replicas.replicas {
+			if sqlDB, err := r.db.DB(); err == nil {
+				m.RegisterPool(fmt.Sprintf("replica-%d", i), sqlDB)
+			}
+		}
+	}
+	return c
+}
+
+// Returns the table of model or an empty string if model is not a model.
+func tableOf(db *gorm.DB, model any) string {
+	if model == nil {
+		return ""
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return ""
+	}
+	return stmt.Table
+}
+
+type opMetricsKey struct{}
+
+// Operation whose statements are recorded, carried by the context of its
+// statements.
+type opMetrics struct {
+	metrics   StatementMetrics
+	operation string
+}
+
+// Returns ctx carrying operation op for the statement metrics of o, if any.
+func (o *orm) withOpMetrics(ctx context.Context, op string) context.Context {
+	m, ok := o.metrics.(StatementMetrics)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, opMetricsKey{}, &opMetrics{metrics: m, operation: op})
+}
+
+const statementStartKey = "realorm:statement_start"
+
+// Notes the start of the statement of db, for its metrics.
+func startStatement(db *gorm.DB) {
+	if db.Statement.Context != nil && db.Statement.Context.Value(opMetricsKey{}) != nil {
+		db.InstanceSet(statementStartKey, time.Now())
+	}
+}
+
+// Records the statement of kind just executed by db in the metrics of its
+// operation.
+func observeStatement(db *gorm.DB, kind string) {
+	if db.DryRun || db.Statement.Context == nil {
+		return
+	}
+
+	m, ok := db.Statement.Context.Value(opMetricsKey{}).(*opMetrics)
+	if !ok {
+		return
+	}
+
+	start, ok := db.InstanceGet(statementStartKey)
+	if !ok {
+		return
+	}
+
+	m.metrics.ObserveStatement(m.operation, db.Statement.Table, kind, outcome(db.Error), time.Since(start.(time.Time)))
+}
+
+// Returns the outcome of an operation that returned err.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return OutcomeNotFound
+	default:
+		return OutcomeError
+	}
+}
+
+// Upper bounds in seconds of the latency histogram buckets
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Records a sample of seconds in the histogram with upper bounds buckets.
+func (h *histogram) observe(buckets []float64, seconds float64) {
+	for i, le := range buckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += seconds
+	h.count++
+}
+
+type queryLabels struct {
+	operation string
+	table     string
+	outcome   string
+}
+
+type statementLabels struct {
+	operation string
+	table     string
+	kind      string
+	outcome   string
+}
+
+// PrometheusMetrics collects metrics in memory and serves them in the
+// Prometheus text exposition format: the latency of operations in
+// realorm_query_duration_seconds, of each of their statements in
+// realorm_statement_duration_seconds, and the statistics of the connection
+// pools, read when the metrics are written.
+type PrometheusMetrics struct {
+	buckets []float64
+
+	mu         sync.Mutex
+	queries    map[queryLabels]*histogram
+	statements map[statementLabels]*histogram
+	pools      map[string]*sql.DB
+}
+
+// NewPrometheusMetrics returns metrics with latency histograms using buckets,
+// or DefaultBuckets if none are given.
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &PrometheusMetrics{
+		buckets:    sorted,
+		queries:    map[queryLabels]*histogram{},
+		statements: map[statementLabels]*histogram{},
+		pools:      map[string]*sql.DB{},
+	}
+}
+
+func (m *PrometheusMetrics) ObserveQuery(operation, table, outcome string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := queryLabels{operation: operation, table: table, outcome: outcome}
+	h, ok := m.queries[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.queries[labels] = h
+	}
+	h.observe(m.buckets, duration.Seconds())
+}
+
+func (m *PrometheusMetrics) ObserveStatement(operation, table, kind, outcome string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := statementLabels{operation: operation, table: table, kind: kind, outcome: outcome}
+	h, ok := m.statements[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.statements[labels] = h
+	}
+	h.observe(m.buckets, duration.Seconds())
+}
+
+func (m *PrometheusMetrics) RegisterPool(name string, db *sql.DB) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pools[name] = db
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format to w.
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	labels := make([]queryLabels, 0, len(m.queries))
+	for l := range m.queries {
+		labels = append(labels, l)
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.operation != b.operation {
+			return a.operation < b.operation
+		}
+		if a.table != b.table {
+			return a.table < b.table
+		}
+		return a.outcome < b.outcome
+	})
+
+	b.WriteString("# HELP realorm_query_duration_seconds Duration of ORM operations, across their statements and retries.\n")
+	b.WriteString("# TYPE realorm_query_duration_seconds histogram\n")
+
+	for _, l := range labels {
+		base := fmt.Sprintf(`operation="%s",table="%s",outcome="%s"`, escapeLabel(l.operation), escapeLabel(l.table), escapeLabel(l.outcome))
+		m.writeHistogram(&b, "realorm_query_duration_seconds", base, m.queries[l])
+	}
+
+	statements := make([]statementLabels, 0, len(m.statements))
+	for l := range m.statements {
+		statements = append(statements, l)
+	}
+
+	sort.Slice(statements, func(i, j int) bool {
+		a, b := statements[i], statements[j]
+		if a.operation != b.operation {
+			return a.operation < b.operation
+		}
+		if a.table != b.table {
+			return a.table < b.table
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.outcome < b.outcome
+	})
+
+	b.WriteString("# HELP realorm_statement_duration_seconds Duration of the SQL statements of ORM operations.\n")
+	b.WriteString("# TYPE realorm_statement_duration_seconds histogram\n")
+
+	for _, l := range statements {
+		base := fmt.Sprintf(`operation="%s",table="%s",kind="%s",outcome="%s"`,
+			escapeLabel(l.operation), escapeLabel(l.table), escapeLabel(l.kind), escapeLabel(l.outcome))
+		m.writeHistogram(&b, "realorm_statement_duration_seconds", base, m.statements[l])
+	}
+
+	pools := make([]string, 0, len(m.pools))
+	for name := range m.pools {
+		pools = append(pools, name)
+	}
+	sort.Strings(pools)
+
+	stats := make([]sql.DBStats, len(pools))
+	for i, name := range pools {
+		stats[i] = m.pools[name].Stats()
+	}
+
+	gauges := []struct {
+		name, help, kind string
+		value            func(s sql.DBStats) float64
+	}{
+		{"realorm_pool_open_connections", "Open connections in the pool.", "gauge",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"realorm_pool_idle_connections", "Idle connections in the pool.", "gauge",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"realorm_pool_in_use_connections", "Connections in use.", "gauge",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"realorm_pool_wait_count_total", "Connections waited for.", "counter",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"realorm_pool_wait_duration_seconds_total", "Time spent waiting for connections.", "counter",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+	}
+
+	for _, g := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", g.name, g.help, g.name, g.kind)
+		for i, name := range pools {
+			fmt.Fprintf(&b, "%s{pool=\"%s\"} %g\n", g.name, escapeLabel(name), g.value(stats[i]))
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Writes the series of histogram h called name with labels base.
+func (m *PrometheusMetrics) writeHistogram(b *strings.Builder, name string, base string, h *histogram) {
+	for i, le := range m.buckets {
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"%g\"} %d\n", name, base, le, h.counts[i])
+	}
+
+	fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, base, h.count)
+	fmt.Fprintf(b, "%s_sum{%s} %g\n", name, base, h.sum)
+	fmt.Fprintf(b, "%s_count{%s} %d\n", name, base, h.count)
+}
+
+// Escapes a label value for the text exposition format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,69 @@
+package realorm_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_Metrics(t *testing.T) {
+	metrics := realorm.NewPrometheusMetrics()
+	orm := realorm.New(filepath.Join(t.TempDir(), "metrics.db"), testDialect).WithMetrics(metrics)
+	if err := orm.Migrate(&Post{}, &Article{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	if err := orm.Create(&Post{ID: UniqueID(), Title: "Hello World", Content: "This is a test post"}); err != nil {
+		t.Fatalf("error creating post: %v\n", err)
+	}
+
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	// the statements of an update with history are recorded one by one
+	article := &Article{ID: UniqueID(), Title: "First"}
+	if err := orm.Create(article); err != nil {
+		t.Fatalf("error creating article: %v\n", err)
+	}
+
+	where := &realorm.WhereClause{Query: "id = ?", Args: []interface{}{article.ID}}
+	if _, err := orm.Update(Article{Title: "Second"}, article.ID, where); err != nil {
+		t.Fatalf("error updating article: %v\n", err)
+	}
+
+	_ = orm.Find(&Post{}, &realorm.WhereClause{Query: "id = ?", Args: []interface{}{0}})
+	_ = orm.FindAll(&posts, &realorm.WhereClause{Query: "invalid_column = ?", Args: []interface{}{1}})
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	expected := []string{
+		"# TYPE realorm_query_duration_seconds histogram",
+		`realorm_query_duration_seconds_count{operation="create",table="posts",outcome="success"} 1`,
+		`realorm_query_duration_seconds_count{operation="find_all",table="posts",outcome="success"} 1`,
+		`realorm_query_duration_seconds_count{operation="find_all",table="posts",outcome="error"} 1`,
+		`realorm_query_duration_seconds_count{operation="find",table="posts",outcome="not_found"} 1`,
+		`realorm_query_duration_seconds_bucket{operation="create",table="posts",outcome="success",le="+Inf"} 1`,
+		`realorm_query_duration_seconds_count{operation="update",table="articles",outcome="success"} 1`,
+		"# TYPE realorm_statement_duration_seconds histogram",
+		`realorm_statement_duration_seconds_count{operation="update",table="articles",kind="select",outcome="success"} 3`,
+		`realorm_statement_duration_seconds_count{operation="update",table="articles",kind="update",outcome="success"} 1`,
+		`realorm_statement_duration_seconds_count{operation="update",table="articles_history",kind="insert",outcome="success"} 1`,
+		`realorm_statement_duration_seconds_count{operation="find",table="posts",kind="select",outcome="not_found"} 1`,
+		`realorm_pool_open_connections{pool="primary"}`,
+		`realorm_pool_wait_count_total{pool="primary"} 0`,
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(string(body), e) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", e, body)
+		}
+	}
+}
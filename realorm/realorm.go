@@ -1,18 +1,37 @@
 package realorm
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/abiiranathan/realorm/database"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
 )
 
 var (
 	ErrNoWhereClause = errors.New("where clause is required")
 )
 
+// Names of the ORM operations
+const (
+	OpFind             = "find"
+	OpFindAll          = "find_all"
+	OpFindAllPaginated = "find_all_paginated"
+	OpCreate           = "create"
+	OpUpdate           = "update"
+	OpDelete           = "delete"
+	OpFindAsOf         = "find_as_of"
+	OpHistory          = "history"
+	OpTransaction      = "transaction"
+	OpUpsert           = "upsert"
+	OpExplain          = "explain"
+)
+
 func GetType(model any) any {
 	return reflect.New(reflect.TypeOf(model)).Interface()
 }
@@ -64,6 +83,12 @@ type Creatable interface {
 	Create(model any) error
 }
 
+type Upsertable interface {
+	// Inserts model struct pointer into the database, or updates the row
+	// with its primary key if there is one
+	Upsert(model any) error
+}
+
 type Updatable interface {
 	// Updates model struct in the database on primary key id
 	Update(updates any, id uint, where *WhereClause) (any, error)
@@ -83,6 +108,7 @@ type BaseModel interface {
 	FindAller
 	FindAllPaginater
 	Creatable
+	Upsertable
 	Updatable
 	Deletable
 }
@@ -92,61 +118,326 @@ type ORM interface {
 
 	GetDB() *gorm.DB
 	Migrate(models ...interface{}) error
+
+	// Loads the version of an entity that was current at the given time
+	FindAsOf(model any, key any, at time.Time) error
+
+	// Returns the archived versions of an entity, oldest first
+	History(model any, key any) ([]HistoryEntry, error)
+
+	// Returns an ORM restricted to the rows of a single tenant
+	ForTenant(id any) ORM
+
+	// Returns an ORM that queries tenant-aware models across all tenants
+	WithoutTenant() ORM
+
+	// Returns an ORM whose reads go to the primary database
+	UsePrimary() ORM
+
+	// Runs fn in a transaction on the primary database
+	Transaction(fn func(tx ORM) error) error
+
+	// Returns an ORM caching reads in c
+	WithCache(c Cache) ORM
+
+	// Returns an ORM whose reads use the cache
+	Cached() ORM
+
+	// Returns an ORM whose reads bypass the cache
+	NoCache() ORM
+
+	// Returns an ORM recording its operations and connection pools in m
+	WithMetrics(m Metrics) ORM
+
+	// Returns an ORM running its operations with ctx
+	WithContext(ctx context.Context) ORM
+
+	// Returns an ORM tracing its operations with t
+	WithTracer(t Tracer) ORM
+
+	// Returns an ORM logging its queries with l
+	WithLogger(l logger.Interface) ORM
+
+	// Returns an ORM logging its queries at level
+	WithLogLevel(level logger.LogLevel) ORM
+
+	// Returns an ORM recording the statements it would execute in r
+	DryRun(r *StatementRecorder) ORM
+
+	// Returns the plans of the queries op would run
+	Explain(op func(o ORM) error, analyze bool) ([]QueryPlan, error)
+
+	// Returns an ORM whose Update and Delete are guarded by s
+	WithSafeguards(s Safeguards) ORM
+
+	// Pings the database and reports its health
+	HealthCheck(ctx context.Context) (*Health, error)
+
+	// Returns an ORM retrying operations failing with transient errors
+	WithRetry(p RetryPolicy) ORM
+
+	// Returns an ORM bounding each operation to d
+	WithQueryTimeout(d time.Duration) ORM
+
+	// Drains the operations in flight and closes the connections
+	Close(ctx context.Context) error
 }
 
 type orm struct {
 	DB *gorm.DB
+	// Dialect of DB, whose error translator the operations use
+	dialect database.DialectString
+
+	// Tenant that queries on tenant-aware models are restricted to
+	tenant any
+	// Run queries on tenant-aware models across all tenants
+	bypassTenant bool
+
+	// Read replicas serving Find, FindAll and FindAllPaginated
+	replicas *replicaSet
+	// Send reads to the primary
+	usePrimary bool
+
+	// Cache of reads, invalidated by writes
+	cache Cache
+	// Invalidations of the tables of cache
+	generations *cacheGenerations
+	// Caches of all copies of the orm, invalidated by writes
+	caches *cacheSet
+	// Prefix of the cache keys, unique per database
+	namespace string
+	// Serve reads from the cache
+	caching bool
+	// Tables written inside a transaction, invalidated on commit
+	pending *[]string
+
+	// Records operations and connection pool statistics
+	metrics Metrics
+
+	// Context of the caller, parent of operation spans
+	ctx context.Context
+	// Traces operations
+	tracer Tracer
+
+	// Logs queries, if not the logger of the database
+	logger logger.Interface
+
+	// Record statements instead of executing them
+	dryRun bool
+
+	// Limits of Update and Delete
+	safeguards *Safeguards
+
+	// Models passed to Migrate
+	migrations *migrations
+
+	// Operations in flight, drained by Close
+	gate *gate
+	// Runs inside an operation admitted by the gate
+	inFlight bool
+	// Set once the write of the operation committed, which is never retried
+	written bool
+
+	// Retries of failed operations
+	retry *RetryPolicy
+
+	// Time limit of each attempt of an operation
+	timeout time.Duration
 }
 
 // Connect to the database with the specified dialect and connection string(dsn)
 // and returns an ORM interface for the database.
 // It panics if the database cannot be connected to.
 // The dsn is the connection string for the database or for postgres a pointer to the database.config
-// object
-func New(dsn any, dialect database.DialectString) ORM {
-	db, err := database.Connect(dsn, dialect)
+// object. options configure the connection, like database.WithCircuitBreaker.
+func New(dsn any, dialect database.DialectString, options ...database.Option) ORM {
+	o, err := open(dsn, dialect, options...)
 	if err != nil {
 		panic(err)
 	}
+	return o
+}
+
+// Connects to the database at dsn.
+func open(dsn any, dialect database.DialectString, options ...database.Option) (*orm, error) {
+	db, err := database.Connect(dsn, dialect, options...)
+	if err != nil {
+		return nil, err
+	}
 
-	return &orm{db}
+	return newORM(db, dsn, dialect), nil
 }
 
 func (o *orm) Find(model any, where *WhereClause) error {
+	return o.run(OpFind, model, func(o *orm) error {
+		return o.find(model, where)
+	})
+}
+
+func (o *orm) FindAll(models any, where *WhereClause) error {
+	return o.run(OpFindAll, models, func(o *orm) error {
+		return o.findAll(models, where)
+	})
+}
+
+func (o *orm) FindAllPaginated(models any, page int, pageSize int, where *WhereClause) (result *PaginatedResult, err error) {
+	err = o.run(OpFindAllPaginated, models, func(o *orm) error {
+		result, err = o.findAllPaginated(models, page, pageSize, where)
+		return err
+	})
+	return result, err
+}
+
+func (o *orm) Create(model any) error {
+	return o.run(OpCreate, model, func(o *orm) error {
+		return o.create(model)
+	})
+}
+
+func (o *orm) Upsert(model any) error {
+	return o.run(OpUpsert, model, func(o *orm) error {
+		return o.upsert(model)
+	})
+}
+
+func (o *orm) Update(updates any, id uint, where *WhereClause) (entity any, err error) {
+	err = o.run(OpUpdate, updates, func(o *orm) error {
+		entity, err = o.update(updates, id, where)
+		return err
+	})
+	return entity, err
+}
+
+func (o *orm) Delete(model any, where *WhereClause) error {
+	return o.run(OpDelete, model, func(o *orm) error {
+		return o.delete(model, where)
+	})
+}
+
+// Runs fn in a transaction on the primary. The transaction is committed if
+// fn returns nil and rolled back otherwise.
+// Reads inside the transaction bypass the cache and cached reads of the
+// tables written are invalidated on commit.
+func (o *orm) Transaction(fn func(tx ORM) error) error {
+	return o.run(OpTransaction, nil, func(o *orm) error {
+		return o.transaction(fn)
+	})
+}
+
+// Runs the operation op on model. fn runs on a copy of the orm whose
+// statements carry the context of the operation.
+func (o *orm) run(op string, model any, fn func(o *orm) error) error {
+	if err := o.enter(); err != nil {
+		return err
+	}
+	defer o.leave()
+
+	start := time.Now()
+	table := tableOf(o.DB, model)
+
+	ctx, span := o.startSpan(op, table)
+	ctx = o.withOpMetrics(ctx, op)
+	attempt := func() (bool, error) {
+		ctx := ctx
+		if o.timeout > 0 && op != OpTransaction {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, o.timeout)
+			defer cancel()
+		}
+		c := o.withContext(ctx)
+		c.inFlight = true
+		err := fn(c)
+		return c.written, err
+	}
+
+	var err error
+	if o.retry != nil {
+		err = o.retry.do(ctx, isWrite(op), attempt)
+	} else {
+		_, err = attempt()
+	}
+
+	err = o.translateError(err)
+	span.end(err)
+
+	if o.metrics != nil {
+		o.metrics.ObserveQuery(op, table, outcome(err), time.Since(start))
+	}
+	return err
+}
+
+func (o *orm) find(model any, where *WhereClause) error {
 	if where == nil {
 		return ErrNoWhereClause
 	}
 
-	return o.DB.Preload(clause.Associations).Where(where.Query, where.Args...).First(model).Error
+	tenant, err := o.tenantScope(model)
+	if err != nil {
+		return err
+	}
 
-}
+	var generation []uint64
+	key, cached := o.cacheKey(OpFind, model, where, 0, 0)
+	if cached {
+		if _, ok := o.fromCache(key, model); ok {
+			return nil
+		}
+		generation = o.cacheGeneration(model)
+	}
 
-func (o *orm) FindAll(models any, where *WhereClause) error {
-	if where != nil {
-		return o.DB.Preload(clause.Associations).Where(where.Query, where.Args...).Find(models).Error
-	} else {
-		return o.DB.Preload(clause.Associations).Find(models).Error
+	err = o.read(func(db *gorm.DB) error {
+		return db.Scopes(tenant, o.preload(model)).Where(where.Query, where.Args...).First(model).Error
+	})
+
+	if err == nil && cached {
+		o.toCache(key, model, 0, generation)
 	}
+	return err
 }
 
-func (o *orm) FindAllPaginated(models any, page int, pageSize int, where *WhereClause) (*PaginatedResult, error) {
-	var count int64
-	var err error
+func (o *orm) findAll(models any, where *WhereClause) error {
+	tenant, err := o.tenantScope(models)
+	if err != nil {
+		return err
+	}
 
-	if where != nil {
-		err = o.DB.Model(models).Where(where.Query, where.Args...).Count(&count).Error
-	} else {
-		err = o.DB.Model(models).Count(&count).Error
+	var generation []uint64
+	key, cached := o.cacheKey(OpFindAll, models, where, 0, 0)
+	if cached {
+		if _, ok := o.fromCache(key, models); ok {
+			return nil
+		}
+		generation = o.cacheGeneration(models)
+	}
+
+	err = o.read(func(db *gorm.DB) error {
+		if where != nil {
+			return db.Scopes(tenant, o.preload(models)).Where(where.Query, where.Args...).Find(models).Error
+		}
+		return db.Scopes(tenant, o.preload(models)).Find(models).Error
+	})
+
+	if err == nil && cached {
+		o.toCache(key, models, 0, generation)
 	}
+	return err
+}
+
+func (o *orm) findAllPaginated(models any, page int, pageSize int, where *WhereClause) (*PaginatedResult, error) {
+	var count int64
 
+	tenant, err := o.tenantScope(models)
 	if err != nil {
 		return nil, err
 	}
 
-	totalPages := int(count) / pageSize
-
-	if int(count)%pageSize > 0 {
-		totalPages++
+	var generation []uint64
+	key, cached := o.cacheKey(OpFindAllPaginated, models, where, page, pageSize)
+	if cached {
+		if entry, ok := o.fromCache(key, models); ok {
+			return newPaginatedResult(models, entry.count, page, pageSize), nil
+		}
+		generation = o.cacheGeneration(models)
 	}
 
 	var offset int
@@ -157,10 +448,59 @@ func (o *orm) FindAllPaginated(models any, page int, pageSize int, where *WhereC
 		offset = (page - 1) * pageSize
 	}
 
-	if where != nil {
-		err = o.DB.Preload(clause.Associations).Model(models).Where(where.Query, where.Args...).Offset(offset).Limit(pageSize).Find(models).Error
-	} else {
-		err = o.DB.Preload(clause.Associations).Model(models).Offset(offset).Limit(pageSize).Find(models).Error
+	err = o.read(func(db *gorm.DB) error {
+		var err error
+		if where != nil {
+			err = db.Scopes(tenant).Model(models).Where(where.Query, where.Args...).Count(&count).Error
+		} else {
+			err = db.Scopes(tenant).Model(models).Count(&count).Error
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if where != nil {
+			return db.Scopes(tenant, o.preload(models)).Model(models).Where(where.Query, where.Args...).Offset(offset).Limit(pageSize).Find(models).Error
+		}
+		return db.Scopes(tenant, o.preload(models)).Model(models).Offset(offset).Limit(pageSize).Find(models).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cached {
+		o.toCache(key, models, count, generation)
+	}
+
+	return newPaginatedResult(models, count, page, pageSize), nil
+
+}
+
+// Returns the condition matching the primary key of model to id.
+func primaryKey(db *gorm.DB, model any, id any) (clause.Expression, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("%s has no primary key", stmt.Schema.Name)
+	}
+
+	return clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: stmt.Schema.PrioritizedPrimaryField.DBName},
+		Value:  id,
+	}, nil
+}
+
+// Returns the page of count results that models holds.
+func newPaginatedResult(models any, count int64, page int, pageSize int) *PaginatedResult {
+	totalPages := int(count) / pageSize
+
+	if int(count)%pageSize > 0 {
+		totalPages++
 	}
 
 	return &PaginatedResult{
@@ -170,53 +510,173 @@ func (o *orm) FindAllPaginated(models any, page int, pageSize int, where *WhereC
 		HasNext:    page < totalPages,
 		HasPrev:    page > 1,
 		Page:       page,
-	}, err
-
+	}
 }
 
-func (o *orm) Create(model any) error {
-	err := o.DB.Model(model).Create(model).Error
+func (o *orm) create(model any) error {
+	if err := o.stampTenant(model); err != nil {
+		return err
+	}
+
+	tenant, err := o.tenantScope(model)
 	if err != nil {
 		return err
 	}
 
+	if isHistorical(model) {
+		err = o.inTransaction(func(tx *gorm.DB) error {
+			if err := tx.Model(model).Create(model).Error; err != nil {
+				return err
+			}
+			return recordCreate(tx, model)
+		})
+	} else {
+		err = o.DB.Model(model).Create(model).Error
+	}
+
+	if err != nil {
+		return err
+	}
+
+	o.written = true
+	o.invalidate(model)
+
 	// refetch the model
-	return o.DB.Preload(clause.Associations).First(&model, model).Error
+	return o.DB.Scopes(tenant, o.preload(model)).First(&model, model).Error
 
 }
 
-func (o *orm) Update(updates any, id uint, where *WhereClause) (any, error) {
+func (o *orm) update(updates any, id uint, where *WhereClause) (any, error) {
 	entity := GetType(updates)
 
 	if where == nil {
 		return nil, ErrNoWhereClause
 	}
 
-	err := o.DB.First(&entity, id).Error
+	tenant, err := o.tenantScope(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.checkWhere(entity, where); err != nil {
+		return nil, err
+	}
+
+	pk, err := primaryKey(o.DB, entity, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.DB.Scopes(tenant).First(&entity, id).Error
 
 	if err != nil {
 		return nil, err
 	}
 
 	// Update the model
-	err = o.DB.Model(&entity).Where(where.Query, where.Args...).Updates(updates).Error
+	err = o.inTransaction(func(tx *gorm.DB) error {
+		if isHistorical(entity) {
+			if _, err := archive(tx, entity, where, HistoryUpdate, tenant); err != nil {
+				return err
+			}
+		}
+
+		// the tenant of an entity never changes
+		result := tx.Scopes(tenant).Model(&entity).Omit(TenantColumn).Where(pk).Where(where.Query, where.Args...).Updates(updates)
+		if err := o.checkRowsAffected(result); err != nil {
+			return err
+		}
+
+		if result.RowsAffected == 0 && o.scopedToTenant(entity) {
+			// mysql reports the rows changed, not the rows matched
+			var matched int64
+			err := tx.Scopes(tenant).Model(entity).Where(pk).Where(where.Query, where.Args...).Count(&matched).Error
+			if err != nil {
+				return err
+			}
+
+			if matched == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	o.written = true
+	o.invalidate(entity)
+
 	// refetch the model
-	err = o.DB.Preload(clause.Associations).Where(where.Query, where.Args...).First(&entity).Error
+	err = o.DB.Scopes(tenant, o.preload(entity)).Where(pk).First(&entity).Error
 	return entity, err
 
 }
 
-func (o *orm) Delete(model any, where *WhereClause) error {
+func (o *orm) delete(model any, where *WhereClause) error {
 	if where == nil {
 		return ErrNoWhereClause
 	}
 
-	return o.DB.Where(where.Query, where.Args).Delete(model).Error
+	tenant, err := o.tenantScope(model)
+	if err != nil {
+		return err
+	}
+
+	if err := o.checkWhere(model, where); err != nil {
+		return err
+	}
+
+	err = o.inTransaction(func(tx *gorm.DB) error {
+		if isHistorical(model) {
+			if _, err := archive(tx, model, where, HistoryDelete, tenant); err != nil {
+				return err
+			}
+		}
+
+		result := tx.Scopes(tenant).Where(where.Query, where.Args...).Delete(model)
+		if err := o.checkRowsAffected(result); err != nil {
+			return err
+		}
+
+		// rows of other tenants are reported as missing
+		if result.RowsAffected == 0 && o.scopedToTenant(model) {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+
+	if err == nil {
+		o.invalidate(model)
+	}
+	return err
+}
+
+func (o *orm) transaction(fn func(tx ORM) error) error {
+	var written []string
+
+	err := o.inTransaction(func(tx *gorm.DB) error {
+		c := o.clone()
+		c.DB = tx
+		c.replicas = nil
+		c.caching = false
+		c.retry = nil
+		c.pending = &written
+		return fn(c)
+	})
+
+	if err == nil && !o.caches.empty() {
+		for _, table := range written {
+			if o.pending != nil {
+				*o.pending = append(*o.pending, table)
+			} else {
+				o.invalidateTable(table)
+			}
+		}
+	}
+	return err
 }
 
 func (o *orm) GetDB() *gorm.DB {
@@ -224,5 +684,18 @@ func (o *orm) GetDB() *gorm.DB {
 }
 
 func (o *orm) Migrate(models ...interface{}) error {
-	return o.DB.AutoMigrate(models...)
+	if err := o.enter(); err != nil {
+		return err
+	}
+	defer o.leave()
+
+	if err := o.DB.AutoMigrate(models...); err != nil {
+		return err
+	}
+
+	if o.migrations != nil {
+		o.migrations.add(models...)
+	}
+
+	return migrateHistory(o.DB, models...)
 }
@@ -3,6 +3,7 @@ package realorm_test
 import (
 	"math"
 	"math/rand"
+	"os"
 	"testing"
 	"time"
 
@@ -16,6 +17,16 @@ type Post struct {
 	Content string `gorm:"type:varchar(1000);not null"`
 }
 
+// Dialect of the sqlite databases of the tests.
+// REALORM_TEST_DIALECT=sqlite runs them on the pure Go driver.
+var testDialect = database.SQLITE_DEFAULT
+
+func init() {
+	if dialect := os.Getenv("REALORM_TEST_DIALECT"); dialect != "" {
+		testDialect = database.DialectString(dialect)
+	}
+}
+
 // Setup
 // setup the database
 
@@ -35,7 +46,7 @@ func clear_table(t *testing.T) {
 }
 
 func create_orm() (realorm.ORM, error) {
-	orm := realorm.New(database.SQLITE3_MEMORY_DB, database.SQLITE3)
+	orm := realorm.New(database.SQLITE3_MEMORY_DB, testDialect)
 	err := orm.Migrate(&Post{})
 	return orm, err
 }
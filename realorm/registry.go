@@ -0,0 +1,247 @@
+package realorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/abiiranathan/realorm/database"
+)
+
+var (
+	ErrUnknownDatabase = errors.New("unknown database")
+)
+
+// Bound is implemented by models that name the database of a Registry they
+// live in. Bind overrides it.
+type Bound interface {
+	DatabaseName() string
+}
+
+// DatabaseConfig describes the connection of a named database.
+type DatabaseConfig struct {
+	// DSN string or database.Config of the database
+	DSN any
+	// Dialect of the database
+	Dialect database.DialectString
+	// Read replicas, chosen with ReplicaPolicy
+	Replicas      []any
+	ReplicaPolicy ReplicaPolicy
+	// Options of the connections, like database.WithCircuitBreaker
+	Options []database.Option
+}
+
+// Registry holds the ORMs of named databases, like "main", "analytics" and
+// "legacy". Models bound to a database with Bind or Bound are routed to it by
+// the Find, FindAll, FindAllPaginated, Create, Upsert, Update and Delete
+// methods of the registry, others go to the default database.
+type Registry struct {
+	mu          sync.RWMutex
+	orms        map[string]*orm
+	defaultName string
+	bindings    map[reflect.Type]string
+}
+
+// NewRegistry connects to the databases of configs and returns a registry
+// holding them. defaultName is the database of models that are not bound,
+// leave it empty to require a binding for every model.
+// If a database cannot be connected to, the others are closed.
+func NewRegistry(configs map[string]DatabaseConfig, defaultName string) (*Registry, error) {
+	if _, ok := configs[defaultName]; defaultName != "" && !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDatabase, defaultName)
+	}
+
+	r := &Registry{orms: map[string]*orm{}, defaultName: defaultName, bindings: map[reflect.Type]string{}}
+
+	// connect in a stable order, so errors are reproducible
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		config := configs[name]
+		o, err := openWithReplicas(config.DSN, config.Replicas, config.Dialect, config.ReplicaPolicy, config.Options...)
+		if err != nil {
+			r.Close(context.Background())
+			return nil, fmt.Errorf("database %s: %w", name, err)
+		}
+		r.orms[name] = o
+	}
+
+	return r, nil
+}
+
+// LoadRegistry loads the configs of the databases called names with
+// database.LoadNamedConfig and connects to them, see NewRegistry.
+// The first name is the default database.
+func LoadRegistry(names []string, files ...string) (*Registry, error) {
+	configs := map[string]DatabaseConfig{}
+	for _, name := range names {
+		c, err := database.LoadNamedConfig(name, files...)
+		if err != nil {
+			return nil, fmt.Errorf("database %s: %w", name, err)
+		}
+		configs[name] = DatabaseConfig{DSN: c, Dialect: c.Dialect()}
+	}
+
+	var defaultName string
+	if len(names) > 0 {
+		defaultName = names[0]
+	}
+	return NewRegistry(configs, defaultName)
+}
+
+// Get returns the ORM of the database called name.
+func (r *Registry) Get(name string) (ORM, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, ok := r.orms[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDatabase, name)
+	}
+	return o, nil
+}
+
+// Names returns the names of the databases, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.orms))
+	for name := range r.orms {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Bind routes the calls of the registry on models to the database called
+// name. A model is a struct or a pointer or slice of structs.
+func (r *Registry) Bind(name string, models ...any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.orms[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownDatabase, name)
+	}
+
+	for _, model := range models {
+		r.bindings[modelType(model)] = name
+	}
+	return nil
+}
+
+// For returns the ORM of the database model is bound to, or of the default
+// database if it is not bound.
+func (r *Registry) For(model any) (ORM, error) {
+	t := modelType(model)
+
+	r.mu.RLock()
+	name, ok := r.bindings[t]
+	if !ok {
+		name = r.defaultName
+	}
+	r.mu.RUnlock()
+
+	if t != nil && !ok {
+		if bound, isBound := reflect.New(t).Interface().(Bound); isBound {
+			name = bound.DatabaseName()
+		}
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: %s is not bound to a database", ErrUnknownDatabase, t)
+	}
+	return r.Get(name)
+}
+
+// Close closes all databases and removes them from the registry, draining
+// their operations in flight until ctx is done, see ORM.Close.
+// It returns the first error.
+func (r *Registry) Close(ctx context.Context) error {
+	r.mu.Lock()
+	orms := r.orms
+	r.orms = map[string]*orm{}
+	r.mu.Unlock()
+
+	var err error
+	for name, o := range orms {
+		if e := o.Close(ctx); e != nil && err == nil {
+			err = fmt.Errorf("database %s: %w", name, e)
+		}
+	}
+	return err
+}
+
+func (r *Registry) Find(model any, where *WhereClause) error {
+	o, err := r.For(model)
+	if err != nil {
+		return err
+	}
+	return o.Find(model, where)
+}
+
+func (r *Registry) FindAll(models any, where *WhereClause) error {
+	o, err := r.For(models)
+	if err != nil {
+		return err
+	}
+	return o.FindAll(models, where)
+}
+
+func (r *Registry) FindAllPaginated(models any, page int, pageSize int, where *WhereClause) (*PaginatedResult, error) {
+	o, err := r.For(models)
+	if err != nil {
+		return nil, err
+	}
+	return o.FindAllPaginated(models, page, pageSize, where)
+}
+
+func (r *Registry) Create(model any) error {
+	o, err := r.For(model)
+	if err != nil {
+		return err
+	}
+	return o.Create(model)
+}
+
+func (r *Registry) Upsert(model any) error {
+	o, err := r.For(model)
+	if err != nil {
+		return err
+	}
+	return o.Upsert(model)
+}
+
+func (r *Registry) Update(updates any, id uint, where *WhereClause) (any, error) {
+	o, err := r.For(updates)
+	if err != nil {
+		return nil, err
+	}
+	return o.Update(updates, id, where)
+}
+
+func (r *Registry) Delete(model any, where *WhereClause) error {
+	o, err := r.For(model)
+	if err != nil {
+		return err
+	}
+	return o.Delete(model, where)
+}
+
+// Returns the struct type of a model, a struct or a pointer, slice or array
+// of structs.
+func modelType(model any) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	return t
+}
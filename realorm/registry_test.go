@@ -0,0 +1,155 @@
+package realorm_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+type Event struct {
+	ID   uint   `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	Name string `gorm:"type:varchar(100);not null"`
+}
+
+func (Event) DatabaseName() string {
+	return "analytics"
+}
+
+func Test_realorm_Registry(t *testing.T) {
+	dir := t.TempDir()
+	registry, err := realorm.NewRegistry(map[string]realorm.DatabaseConfig{
+		"main":      {DSN: filepath.Join(dir, "main.db"), Dialect: testDialect},
+		"analytics": {DSN: filepath.Join(dir, "analytics.db"), Dialect: testDialect},
+		"legacy":    {DSN: filepath.Join(dir, "legacy.db"), Dialect: testDialect},
+	}, "main")
+
+	if err != nil {
+		t.Fatalf("error creating registry: %v\n", err)
+	}
+	defer registry.Close(context.Background())
+
+	main, _ := registry.Get("main")
+	analytics, _ := registry.Get("analytics")
+	legacy, _ := registry.Get("legacy")
+
+	if err := main.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating main: %v\n", err)
+	}
+
+	if err := analytics.Migrate(&Event{}); err != nil {
+		t.Fatalf("error migrating analytics: %v\n", err)
+	}
+
+	if err := legacy.Migrate(&Article{}); err != nil {
+		t.Fatalf("error migrating legacy: %v\n", err)
+	}
+
+	if err := registry.Bind("legacy", Article{}); err != nil {
+		t.Fatalf("error binding articles: %v\n", err)
+	}
+
+	// each model goes to its own database
+	if err := registry.Create(&Post{ID: 1, Title: "Hello", Content: "World"}); err != nil {
+		t.Errorf("error creating post: %v\n", err)
+	}
+
+	if err := registry.Create(&Event{ID: 1, Name: "signup"}); err != nil {
+		t.Errorf("error creating event: %v\n", err)
+	}
+
+	if err := registry.Create(&Article{ID: 1, Title: "First"}); err != nil {
+		t.Errorf("error creating article: %v\n", err)
+	}
+
+	var events []Event
+	if err := registry.FindAll(&events, nil); err != nil || len(events) != 1 {
+		t.Errorf("expected 1 event, got %d and %v", len(events), err)
+	}
+
+	var articles []Article
+	if err := legacy.FindAll(&articles, nil); err != nil || len(articles) != 1 {
+		t.Errorf("expected 1 article in legacy, got %d and %v", len(articles), err)
+	}
+
+	var posts []Post
+	if err := main.FindAll(&posts, nil); err != nil || len(posts) != 1 {
+		t.Errorf("expected 1 post in main, got %d and %v", len(posts), err)
+	}
+
+	if err := main.FindAll(&events, nil); err == nil {
+		t.Errorf("expected error finding events in main, got nil")
+	}
+
+	if _, err := registry.Get("warehouse"); !errors.Is(err, realorm.ErrUnknownDatabase) {
+		t.Errorf("expected ErrUnknownDatabase, got %v", err)
+	}
+
+	if err := registry.Bind("warehouse", Post{}); !errors.Is(err, realorm.ErrUnknownDatabase) {
+		t.Errorf("expected ErrUnknownDatabase, got %v", err)
+	}
+
+	if err := registry.Close(context.Background()); err != nil {
+		t.Fatalf("error closing registry: %v\n", err)
+	}
+
+	if len(registry.Names()) != 0 {
+		t.Errorf("expected no databases after close, got %v", registry.Names())
+	}
+
+	if err := main.FindAll(&posts, nil); !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed using a closed database, got %v", err)
+	}
+
+	// a failing database closes the others
+	_, err = realorm.NewRegistry(map[string]realorm.DatabaseConfig{
+		"main":   {DSN: filepath.Join(dir, "main.db"), Dialect: testDialect},
+		"oracle": {DSN: "oracle://db", Dialect: "oracle"},
+	}, "main")
+
+	if err == nil {
+		t.Errorf("expected error connecting to an unknown dialect, got nil")
+	}
+}
+
+func Test_realorm_RegistryCloseUnlocked(t *testing.T) {
+	registry, err := realorm.NewRegistry(map[string]realorm.DatabaseConfig{
+		"main": {DSN: filepath.Join(t.TempDir(), "main.db"), Dialect: testDialect},
+	}, "main")
+
+	if err != nil {
+		t.Fatalf("error creating registry: %v\n", err)
+	}
+
+	main, _ := registry.Get("main")
+	if err := main.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating main: %v\n", err)
+	}
+
+	release := make(chan struct{})
+	done := blocked_transaction(main, release)
+
+	closed := make(chan error, 1)
+	go func() { closed <- registry.Close(context.Background()) }()
+
+	// the registry stays usable while main drains
+	for len(registry.Names()) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := registry.Get("main"); !errors.Is(err, realorm.ErrUnknownDatabase) {
+		t.Errorf("expected ErrUnknownDatabase while draining, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("error finishing transaction: %v\n", err)
+	}
+
+	if err := <-closed; err != nil {
+		t.Errorf("error closing registry: %v\n", err)
+	}
+}
@@ -0,0 +1,239 @@
+package realorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/abiiranathan/realorm/database"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// ReplicaPolicy chooses the replica that serves a read.
+type ReplicaPolicy int
+
+const (
+	// Use replicas in turn
+	RoundRobin ReplicaPolicy = iota
+	// Use a random replica
+	Random
+)
+
+// Time an unreachable replica is ejected for before it is pinged again.
+var ReplicaCooldown = 30 * time.Second
+
+type replica struct {
+	db *gorm.DB
+
+	mu           sync.Mutex
+	ejectedUntil time.Time
+	// An ejected replica is being pinged
+	probing bool
+}
+
+type replicaSet struct {
+	replicas []*replica
+	policy   ReplicaPolicy
+	next     uint64
+}
+
+// NewWithReplicas connects to a primary database and its read replicas and
+// returns an ORM for them.
+// Find, FindAll and FindAllPaginated are served by the replicas chosen with
+// policy. Writes, reads inside transactions and reads after UsePrimary go to
+// the primary. Replicas failing with connection errors are ejected for
+// ReplicaCooldown and their read is tried again on the next healthy replica.
+// Reads go to the primary when all replicas are ejected.
+// It panics if any database cannot be connected to. options configure every
+// connection, so each gets its own circuit breaker.
+func NewWithReplicas(primary any, replicas []any, dialect database.DialectString, policy ReplicaPolicy, options ...database.Option) ORM {
+	o, err := openWithReplicas(primary, replicas, dialect, policy, options...)
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+// Connects to a primary database and its read replicas. The connections
+// already opened are closed if one fails.
+func openWithReplicas(primary any, replicas []any, dialect database.DialectString, policy ReplicaPolicy, options ...database.Option) (*orm, error) {
+	o, err := open(primary, dialect, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	o.replicas = &replicaSet{policy: policy}
+	for _, dsn := range replicas {
+		rdb, err := database.Connect(dsn, dialect, options...)
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+
+		registerCallbacks(rdb)
+		o.replicas.replicas = append(o.replicas.replicas, &replica{db: rdb})
+	}
+
+	return o, nil
+}
+
+// Closes the connection pools of the primary and the replicas.
+func (o *orm) close() error {
+	err := closeDB(o.DB)
+	if o.replicas != nil {
+		for _, r := range o.replicas.replicas {
+			if e := closeDB(r.db); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// UsePrimary returns an ORM whose reads go to the primary, to read your own
+// writes.
+func (o *orm) UsePrimary() ORM {
+	c := o.clone()
+	c.usePrimary = true
+	return c
+}
+
+// Returns the database to read from and the replica it belongs to, if any.
+func (o *orm) reader() (*gorm.DB, *replica) {
+	if o.replicas == nil || o.usePrimary {
+		return o.DB, nil
+	}
+
+	if r := o.replicas.pick(); r != nil {
+		db := r.db
+		if o.logger != nil {
+			db = db.Session(&gorm.Session{Logger: o.logger})
+		}
+		return db.WithContext(o.context()), r
+	}
+	return o.DB, nil
+}
+
+// Runs the read fn on a healthy replica. A replica failing with a connection
+// error is ejected and fn runs again on the next healthy replica, or on the
+// primary once every replica was tried.
+func (o *orm) read(fn func(db *gorm.DB) error) error {
+	db, r := o.reader()
+	for tries := 1; r != nil; tries++ {
+		err := fn(db)
+		if !isConnectionError(err) || o.context().Err() != nil {
+			return err
+		}
+
+		r.report(err)
+		if tries < len(o.replicas.replicas) {
+			db, r = o.reader()
+		} else {
+			db, r = o.DB, nil
+		}
+	}
+	return fn(db)
+}
+
+// Returns a healthy replica or nil if all replicas are ejected.
+func (s *replicaSet) pick() *replica {
+	n := len(s.replicas)
+	if n == 0 {
+		return nil
+	}
+
+	var start int
+	if s.policy == Random {
+		start = rand.Intn(n)
+	} else {
+		start = int(atomic.AddUint64(&s.next, 1) % uint64(n))
+	}
+
+	for i := 0; i < n; i++ {
+		if r := s.replicas[(start+i)%n]; r.healthy() {
+			return r
+		}
+	}
+	return nil
+}
+
+// Pings replicas, replaced by tests.
+var pingReplica = ping
+
+// Returns true if the replica is not ejected. Once the cooldown of an
+// ejected replica has passed, one goroutine pings it in the background and
+// reinstates it if it responds. Until then the replica stays ejected, so
+// reads never wait for the ping.
+func (r *replica) healthy() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ejectedUntil.IsZero() {
+		return true
+	}
+
+	if r.probing || time.Now().Before(r.ejectedUntil) {
+		return false
+	}
+
+	r.probing = true
+	go r.probe()
+	return false
+}
+
+// Pings an ejected replica and reinstates it if it responds.
+func (r *replica) probe() {
+	err := pingReplica(r.db)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.probing = false
+	if err != nil {
+		r.ejectedUntil = time.Now().Add(ReplicaCooldown)
+		return
+	}
+	r.ejectedUntil = time.Time{}
+}
+
+// Ejects the replica if err is a connection error.
+// r may be nil for reads served by the primary.
+func (r *replica) report(err error) {
+	if r == nil || !isConnectionError(err) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ejectedUntil = time.Now().Add(ReplicaCooldown)
+}
+
+// Pings the database with a short timeout.
+func ping(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// Returns true if err means the database could not be reached. Deadlines
+// and cancellations of the caller are not, even though drivers may report
+// them as network timeouts, since a slow query says nothing of the server.
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var opErr *net.OpError
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &opErr)
+}
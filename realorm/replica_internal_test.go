@@ -0,0 +1,154 @@
+package realorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/realorm/database"
+	"gorm.io/gorm"
+)
+
+func TestReplicaProbe(t *testing.T) {
+	var pings atomic.Int32
+	release := make(chan struct{})
+	pingReplica = func(db *gorm.DB) error {
+		pings.Add(1)
+		<-release
+		return nil
+	}
+	defer func() { pingReplica = ping }()
+
+	r := &replica{ejectedUntil: time.Now().Add(-time.Second)}
+
+	// readers never wait for the ping of an ejected replica
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if r.healthy() {
+			t.Fatalf("expected the replica to stay ejected while it is pinged")
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("healthy blocked for %v", elapsed)
+	}
+
+	close(release)
+	for !r.healthy() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if pings.Load() != 1 {
+		t.Errorf("expected 1 ping, got %d", pings.Load())
+	}
+}
+
+type replicaItem struct {
+	ID   uint
+	Name string
+}
+
+// Opens an orm with a primary and n replicas in sqlite files of a temporary
+// directory, all holding the table of replicaItem.
+func openTestReplicas(t *testing.T, n int) *orm {
+	dir := t.TempDir()
+	dsns := make([]any, n)
+	for i := range dsns {
+		dsns[i] = filepath.Join(dir, fmt.Sprintf("replica%d.db", i))
+	}
+
+	o, err := openWithReplicas(filepath.Join(dir, "primary.db"), dsns, database.SQLITE_DEFAULT, RoundRobin)
+	if err != nil {
+		t.Fatalf("error connecting: %v\n", err)
+	}
+	t.Cleanup(func() { o.close() })
+
+	if err := o.DB.AutoMigrate(&replicaItem{}); err != nil {
+		t.Fatalf("error migrating primary: %v\n", err)
+	}
+
+	for _, r := range o.replicas.replicas {
+		if err := r.db.AutoMigrate(&replicaItem{}); err != nil {
+			t.Fatalf("error migrating replica: %v\n", err)
+		}
+	}
+	return o
+}
+
+// Makes every query on db fail with err.
+func failQueries(t *testing.T, db *gorm.DB, err error) {
+	e := db.Callback().Query().Before("gorm:query").Register("test:fail", func(db *gorm.DB) {
+		db.AddError(err)
+	})
+
+	if e != nil {
+		t.Fatalf("error registering callback: %v\n", e)
+	}
+}
+
+func TestReplicaFailover(t *testing.T) {
+	o := openTestReplicas(t, 2)
+	if err := o.DB.Create(&replicaItem{ID: 1, Name: "primary"}).Error; err != nil {
+		t.Fatalf("error creating item: %v\n", err)
+	}
+
+	for _, r := range o.replicas.replicas {
+		failQueries(t, r.db, driver.ErrBadConn)
+	}
+
+	// the read is served by the primary once both replicas failed
+	var items []replicaItem
+	if err := o.FindAll(&items, nil); err != nil {
+		t.Fatalf("expected the read to fail over, got %v\n", err)
+	}
+
+	if len(items) != 1 || items[0].Name != "primary" {
+		t.Errorf("expected the item of the primary, got %+v", items)
+	}
+
+	for i, r := range o.replicas.replicas {
+		if r.healthy() {
+			t.Errorf("expected replica %d to be ejected", i)
+		}
+	}
+
+	// a healthy replica serves the read of a failed one
+	o = openTestReplicas(t, 2)
+	failQueries(t, o.replicas.replicas[0].db, driver.ErrBadConn)
+	if err := o.replicas.replicas[1].db.Create(&replicaItem{ID: 1, Name: "replica"}).Error; err != nil {
+		t.Fatalf("error creating item: %v\n", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		var item replicaItem
+		if err := o.Find(&item, &WhereClause{Query: "id = ?", Args: []any{1}}); err != nil || item.Name != "replica" {
+			t.Errorf("expected the item of the healthy replica, got %+v and %v", item, err)
+		}
+	}
+}
+
+func TestReplicaTimeoutNotEjected(t *testing.T) {
+	o := openTestReplicas(t, 1)
+
+	var items []replicaItem
+	err := o.WithQueryTimeout(time.Nanosecond).FindAll(&items, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the query to time out, got %v", err)
+	}
+
+	// a slow query says nothing of the health of the replica
+	if !o.replicas.replicas[0].healthy() {
+		t.Errorf("expected the replica to stay in rotation after a timeout")
+	}
+
+	timeout := &net.OpError{Op: "read", Net: "tcp", Err: context.DeadlineExceeded}
+	if isConnectionError(timeout) || isConnectionError(context.Canceled) {
+		t.Errorf("expected deadlines not to be connection errors")
+	}
+}
@@ -0,0 +1,72 @@
+package realorm_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_Replicas(t *testing.T) {
+	dir := t.TempDir()
+	primaryDSN := filepath.Join(dir, "primary.db")
+	replicaDSN := filepath.Join(dir, "replica.db")
+
+	// seed the replica with a post the primary does not have
+	replica := realorm.New(replicaDSN, testDialect)
+	if err := replica.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating replica: %v\n", err)
+	}
+
+	if err := replica.Create(&Post{ID: UniqueID(), Title: "Replica", Content: "replica"}); err != nil {
+		t.Fatalf("error creating post: %v\n", err)
+	}
+
+	orm := realorm.NewWithReplicas(primaryDSN, []any{replicaDSN, replicaDSN}, testDialect, realorm.RoundRobin)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating primary: %v\n", err)
+	}
+
+	var posts []Post
+	for i := 0; i < 2; i++ {
+		if err := orm.FindAll(&posts, nil); err != nil {
+			t.Fatalf("error finding posts: %v\n", err)
+		}
+
+		if len(posts) != 1 {
+			t.Errorf("expected reads from the replica, got %d posts", len(posts))
+		}
+	}
+
+	result, err := orm.FindAllPaginated(&posts, 1, 10, nil)
+	if err != nil {
+		t.Fatalf("error paginating posts: %v\n", err)
+	}
+
+	if result.Count != 1 {
+		t.Errorf("expected reads from the replica, got %d posts", result.Count)
+	}
+
+	if err := orm.UsePrimary().FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 0 {
+		t.Errorf("expected reads from the primary, got %d posts", len(posts))
+	}
+
+	err = orm.Transaction(func(tx realorm.ORM) error {
+		if err := tx.Create(&Post{ID: UniqueID(), Title: "Primary", Content: "primary"}); err != nil {
+			return err
+		}
+		return tx.FindAll(&posts, nil)
+	})
+
+	if err != nil {
+		t.Fatalf("error in transaction: %v\n", err)
+	}
+
+	if len(posts) != 1 || posts[0].Title != "Primary" {
+		t.Errorf("expected reads from the primary inside transactions, got %+v", posts)
+	}
+}
@@ -0,0 +1,174 @@
+package realorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/abiiranathan/realorm/database"
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// RetryPolicy retries operations failing with transient errors, waiting an
+// exponentially growing, jittered delay between attempts.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first. Zero or one disables
+	// retries.
+	MaxAttempts int
+	// Delay before the first retry. Defaults to 10ms.
+	BaseDelay time.Duration
+	// Upper bound of the delay. Defaults to 1s.
+	MaxDelay time.Duration
+	// Reports whether err is worth retrying. Defaults to IsTransient.
+	Retryable func(err error) bool
+	// Called before each retry, with the number of the failed attempt.
+	OnRetry func(attempt int, err error, delay time.Duration)
+	// Retry Create, Upsert, Update, Delete and Transaction after connection
+	// errors that may have happened after the write committed, so it may be
+	// applied twice. By default writes are only retried after errors known
+	// to happen before commit, like deadlocks and refused connections.
+	RetryAmbiguousWrites bool
+}
+
+// DefaultRetryPolicy retries transient errors up to 3 times.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    time.Second,
+}
+
+// WithRetry returns an ORM retrying its operations according to p.
+// Transaction retries the whole transaction; operations inside a
+// transaction are never retried on their own. Writes are not retried after
+// errors that may have happened after commit, unless p.RetryAmbiguousWrites
+// is set.
+func (o *orm) WithRetry(p RetryPolicy) ORM {
+	c := o.clone()
+	c.retry = &p
+	return c
+}
+
+// IsTransient returns true if err is a deadlock, a serialization failure, a
+// lock wait timeout, a busy or locked sqlite database or a dropped
+// connection, all of which may succeed if tried again. Expired deadlines are
+// not, retrying them would only add load to a slow database.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// serialization_failure and deadlock_detected
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// ER_LOCK_DEADLOCK and ER_LOCK_WAIT_TIMEOUT
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		// deadlock victim and lock request timeout
+		return mssqlErr.Number == 1205 || mssqlErr.Number == 1222
+	}
+
+	if code, ok := database.SQLiteErrorCode(err); ok {
+		code &= 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+
+	return isConnectionError(err)
+}
+
+// Returns true if err may have happened after a write committed, because
+// the connection broke after the statement was sent. driver.ErrBadConn is
+// only returned before anything is sent and refused connections never sent
+// anything.
+func mayHaveCommitted(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return false
+	}
+	return isConnectionError(err)
+}
+
+// Returns true if op writes to the database.
+func isWrite(op string) bool {
+	switch op {
+	case OpCreate, OpUpsert, OpUpdate, OpDelete, OpTransaction:
+		return true
+	}
+	return false
+}
+
+// Runs fn until it succeeds, fails with an error that is not retryable or
+// runs out of attempts. It returns the last error of fn. fn reports whether
+// its write committed, after which it is never retried, like when the
+// refetch of a created model fails. Writes are not retried after errors that
+// may have happened after commit either.
+func (p *RetryPolicy) do(ctx context.Context, write bool, fn func() (bool, error)) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+
+	for attempt := 1; ; attempt++ {
+		written, err := fn()
+		if err == nil || written || attempt >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		if write && !p.RetryAmbiguousWrites && mayHaveCommitted(err) {
+			return err
+		}
+
+		delay := p.delay(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Returns the delay after the failed attempt, between half and all of
+// BaseDelay doubled for every previous attempt, capped at MaxDelay.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	base, max := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = 10 * time.Millisecond
+	}
+
+	if max <= 0 {
+		max = time.Second
+	}
+
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
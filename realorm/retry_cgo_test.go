@@ -0,0 +1,28 @@
+//go:build cgo
+
+package realorm_test
+
+import (
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+	"github.com/mattn/go-sqlite3"
+)
+
+func Test_realorm_IsTransientSQLite3(t *testing.T) {
+	cases := []struct {
+		err       error
+		transient bool
+	}{
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{sqlite3.Error{Code: sqlite3.ErrLocked}, true},
+		{sqlite3.Error{Code: sqlite3.ErrBusy, ExtendedCode: sqlite3.ErrBusyRecovery}, true},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint}, false},
+	}
+
+	for _, c := range cases {
+		if got := realorm.IsTransient(c.err); got != c.transient {
+			t.Errorf("IsTransient(%v) = %v, expected %v", c.err, got, c.transient)
+		}
+	}
+}
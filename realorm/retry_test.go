@@ -0,0 +1,282 @@
+package realorm_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/realorm/realorm"
+	_ "github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+func Test_realorm_IsTransient(t *testing.T) {
+	cases := []struct {
+		err       error
+		transient bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&pgconn.PgError{Code: "40P01"}, true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&mysql.MySQLError{Number: 1213}, true},
+		{&mysql.MySQLError{Number: 1205}, true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{fmt.Errorf("commit: %w", mysql.ErrInvalidConn), true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{context.DeadlineExceeded, false},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+		{&net.OpError{Op: "read", Net: "tcp", Err: context.DeadlineExceeded}, false},
+		{context.Canceled, false},
+		{errors.New("syntax error"), false},
+		{nil, false},
+	}
+
+	for _, c := range cases {
+		if got := realorm.IsTransient(c.err); got != c.transient {
+			t.Errorf("IsTransient(%v) = %v, expected %v", c.err, got, c.transient)
+		}
+	}
+}
+
+// Returns the SQLITE_BUSY and SQLITE_LOCKED errors of the cgo-free driver.
+func sqliteLockErrors(t *testing.T) (busy, locked error) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "locked.db") + "?_pragma=busy_timeout(0)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("error opening database: %v\n", err)
+	}
+	defer db.Close()
+
+	a, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("error getting connection: %v\n", err)
+	}
+	defer a.Close()
+
+	b, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("error getting connection: %v\n", err)
+	}
+	defer b.Close()
+
+	if _, err := a.ExecContext(ctx, "CREATE TABLE t (id int)"); err != nil {
+		t.Fatalf("error creating table: %v\n", err)
+	}
+
+	if _, err := a.ExecContext(ctx, "INSERT INTO t VALUES (1), (2)"); err != nil {
+		t.Fatalf("error inserting rows: %v\n", err)
+	}
+
+	// a table cannot be dropped while a statement on the same connection reads it
+	rows, err := a.QueryContext(ctx, "SELECT id FROM t")
+	if err != nil {
+		t.Fatalf("error querying table: %v\n", err)
+	}
+	rows.Next()
+	_, locked = a.ExecContext(ctx, "DROP TABLE t")
+	rows.Close()
+
+	// another connection cannot write while the database is exclusively locked
+	if _, err := a.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("error locking database: %v\n", err)
+	}
+	defer a.ExecContext(ctx, "ROLLBACK")
+
+	_, busy = b.ExecContext(ctx, "INSERT INTO t VALUES (3)")
+	return busy, locked
+}
+
+func Test_realorm_IsTransientSQLite(t *testing.T) {
+	busy, locked := sqliteLockErrors(t)
+	if busy == nil || locked == nil {
+		t.Fatalf("expected busy and locked errors, got %v and %v", busy, locked)
+	}
+
+	for _, err := range []error{busy, locked, fmt.Errorf("insert: %w", busy)} {
+		if !realorm.IsTransient(err) {
+			t.Errorf("expected %v to be transient", err)
+		}
+	}
+}
+
+func Test_realorm_Retry(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "retry.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	var retries []int
+	policy := realorm.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			retries = append(retries, attempt)
+		},
+	}
+
+	retrying := orm.WithRetry(policy)
+
+	// the whole transaction is retried
+	attempts := 0
+	err := retrying.Transaction(func(tx realorm.ORM) error {
+		attempts++
+		if err := tx.Create(&Post{ID: 1, Title: "Hello", Content: "World"}); err != nil {
+			return err
+		}
+
+		if attempts < 3 {
+			return &mysql.MySQLError{Number: 1213}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("error running transaction: %v\n", err)
+	}
+
+	if attempts != 3 || len(retries) != 2 {
+		t.Errorf("expected 3 attempts and 2 retries, got %d and %v", attempts, retries)
+	}
+
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 1 {
+		t.Errorf("expected 1 post, got %d", len(posts))
+	}
+
+	// attempts run out
+	attempts = 0
+	err = retrying.Transaction(func(tx realorm.ORM) error {
+		attempts++
+		return &mysql.MySQLError{Number: 1205}
+	})
+
+	if attempts != 3 || !realorm.IsTransient(err) {
+		t.Errorf("expected 3 attempts ending in a transient error, got %d and %v", attempts, err)
+	}
+
+	// errors that are not transient are returned at once
+	attempts = 0
+	_ = retrying.Transaction(func(tx realorm.ORM) error {
+		attempts++
+		return errors.New("invalid input")
+	})
+
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+
+	// per call policies replace the policy of the ORM
+	attempts = 0
+	_ = retrying.WithRetry(realorm.RetryPolicy{MaxAttempts: 1}).Transaction(func(tx realorm.ORM) error {
+		attempts++
+		return &mysql.MySQLError{Number: 1213}
+	})
+
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func Test_realorm_RetryWrites(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "retry.db"), testDialect)
+	policy := realorm.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	// a connection dropped mid-statement may have committed the write
+	dropped := &net.OpError{Op: "read", Net: "tcp", Err: io.ErrUnexpectedEOF}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	cases := []struct {
+		policy   realorm.RetryPolicy
+		err      error
+		attempts int
+	}{
+		{policy, dropped, 1},
+		{policy, fmt.Errorf("commit: %w", mysql.ErrInvalidConn), 1},
+		{policy, refused, 3},
+		{policy, fmt.Errorf("query: %w", driver.ErrBadConn), 3},
+		{policy, &mysql.MySQLError{Number: 1213}, 3},
+		{realorm.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryAmbiguousWrites: true}, dropped, 3},
+	}
+
+	for _, c := range cases {
+		attempts := 0
+		_ = orm.WithRetry(c.policy).Transaction(func(tx realorm.ORM) error {
+			attempts++
+			return c.err
+		})
+
+		if attempts != c.attempts {
+			t.Errorf("expected %d attempts after %v, got %d", c.attempts, c.err, attempts)
+		}
+	}
+}
+
+func Test_realorm_RetryRefetch(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "retry.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	// the refetch after a committed write fails once, after skip queries
+	errRefetch := errors.New("refetch failed")
+	skip, failures := 0, 0
+	err := orm.GetDB().Callback().Query().Before("gorm:query").Register("test:fail_refetch", func(db *gorm.DB) {
+		if skip > 0 {
+			skip--
+		} else if failures > 0 {
+			failures--
+			db.AddError(errRefetch)
+		}
+	})
+
+	if err != nil {
+		t.Fatalf("error registering callback: %v\n", err)
+	}
+
+	attempts := 0
+	retrying := orm.WithRetry(realorm.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Retryable:   func(err error) bool { return errors.Is(err, errRefetch) },
+		OnRetry:     func(int, error, time.Duration) { attempts++ },
+	})
+
+	failures = 1
+	if err := retrying.Create(&Post{Title: "Hello", Content: "World"}); !errors.Is(err, errRefetch) {
+		t.Errorf("expected the refetch error, got %v", err)
+	}
+
+	// update reads the row before writing it
+	skip, failures = 1, 1
+	if _, err := retrying.Update(Post{Title: "Updated"}, 1, &realorm.WhereClause{Query: "1 = 1"}); !errors.Is(err, errRefetch) {
+		t.Errorf("expected the refetch error, got %v", err)
+	}
+
+	if attempts != 0 {
+		t.Errorf("expected committed writes not to be retried, got %d retries", attempts)
+	}
+
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 1 || posts[0].Title != "Updated" {
+		t.Errorf("expected a single updated post, got %+v", posts)
+	}
+}
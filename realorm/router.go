@@ -0,0 +1,302 @@
+package realorm
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/abiiranathan/realorm/database"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidTenant = errors.New("invalid tenant id")
+)
+
+// Tenant ids usable as schema names
+var schemaName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TenantConnection describes where the data of a tenant lives.
+type TenantConnection struct {
+	// DSN string or, for postgres, a database.PostgresConfig or a pointer to one
+	DSN any
+	// Dialect of the tenant database
+	Dialect database.DialectString
+	// Postgres schema of the tenant. It is set as the search_path of the
+	// connection. The name is quoted, so it is case sensitive.
+	// Leave empty for database-per-tenant.
+	Schema string
+	// Options of the connection, like database.WithCircuitBreaker
+	Options []database.Option
+}
+
+// TenantResolver maps a tenant id to its connection.
+type TenantResolver func(tenant string) (*TenantConnection, error)
+
+// SchemaResolver returns a resolver placing every tenant in its own schema
+// of the postgres database at dsn. The schema is named after the tenant and
+// created when the tenant is first connected.
+func SchemaResolver(dsn string) TenantResolver {
+	return func(tenant string) (*TenantConnection, error) {
+		if !schemaName.MatchString(tenant) {
+			return nil, ErrInvalidTenant
+		}
+
+		return &TenantConnection{DSN: dsn, Dialect: database.PG, Schema: tenant}, nil
+	}
+}
+
+// SQLiteResolver returns a resolver placing every tenant in its own
+// <tenant>.db sqlite file in directory dir, opened with the
+// database.SQLITE_DEFAULT dialect.
+func SQLiteResolver(dir string) TenantResolver {
+	return func(tenant string) (*TenantConnection, error) {
+		if tenant == "" || tenant == "." || tenant == ".." || filepath.Base(tenant) != tenant {
+			return nil, ErrInvalidTenant
+		}
+
+		return &TenantConnection{DSN: filepath.Join(dir, tenant+".db"), Dialect: database.SQLITE_DEFAULT}, nil
+	}
+}
+
+type tenantEntry struct {
+	tenant string
+	orm    *orm
+}
+
+// Connection of a tenant being opened, shared by the callers asking for it.
+type tenantDial struct {
+	done chan struct{}
+	orm  *orm
+	err  error
+}
+
+// TenantRouter hands out an ORM per tenant for schema-per-tenant and
+// database-per-tenant setups.
+// Connections are opened lazily and at most maxOpen are kept open. The least
+// recently used connection is closed when the limit is reached, after its
+// operations in flight finish. Its ORM then fails with ErrClosed, so fetch
+// the ORM of a tenant from the router for every unit of work.
+type TenantRouter struct {
+	resolve TenantResolver
+	maxOpen int
+
+	mu      sync.Mutex
+	lru     *list.List
+	tenants map[string]*list.Element
+	dialing map[string]*tenantDial
+	closed  bool
+}
+
+// NewTenantRouter returns a router resolving tenant connections with resolve.
+// A maxOpen below 1 means no limit.
+func NewTenantRouter(resolve TenantResolver, maxOpen int) *TenantRouter {
+	return &TenantRouter{
+		resolve: resolve,
+		maxOpen: maxOpen,
+		lru:     list.New(),
+		tenants: map[string]*list.Element{},
+		dialing: map[string]*tenantDial{},
+	}
+}
+
+// ORM returns the ORM of tenant, connecting to its database if needed.
+// Concurrent calls for a tenant being connected wait for that connection,
+// calls for other tenants do not. It returns ErrClosed after Close.
+func (r *TenantRouter) ORM(tenant string) (ORM, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	if e, ok := r.tenants[tenant]; ok {
+		r.lru.MoveToFront(e)
+		r.mu.Unlock()
+		return e.Value.(*tenantEntry).orm, nil
+	}
+
+	if d, ok := r.dialing[tenant]; ok {
+		r.mu.Unlock()
+		<-d.done
+		if d.err != nil {
+			return nil, d.err
+		}
+		return d.orm, nil
+	}
+
+	d := &tenantDial{done: make(chan struct{})}
+	r.dialing[tenant] = d
+	r.mu.Unlock()
+
+	d.orm, d.err = r.connect(tenant)
+
+	r.mu.Lock()
+	delete(r.dialing, tenant)
+	if d.err == nil && r.closed {
+		closeDB(d.orm.DB)
+		d.orm, d.err = nil, ErrClosed
+	}
+
+	if d.err == nil {
+		r.tenants[tenant] = r.lru.PushFront(&tenantEntry{tenant: tenant, orm: d.orm})
+
+		for r.maxOpen > 0 && r.lru.Len() > r.maxOpen {
+			r.evict(r.lru.Back())
+		}
+	}
+	r.mu.Unlock()
+	close(d.done)
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.orm, nil
+}
+
+// Len returns the number of open tenant connections.
+func (r *TenantRouter) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lru.Len()
+}
+
+// MigrateAll migrates models in the database of every tenant in tenants.
+func (r *TenantRouter) MigrateAll(tenants []string, models ...interface{}) error {
+	for _, tenant := range tenants {
+		o, err := r.ORM(tenant)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+
+		if err := o.Migrate(models...); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the connections of all tenants, draining their operations in
+// flight until ctx is done, see ORM.Close. Later calls to ORM fail with
+// ErrClosed. It returns the first error.
+func (r *TenantRouter) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	entries := make([]*tenantEntry, 0, r.lru.Len())
+	for r.lru.Len() > 0 {
+		entries = append(entries, r.remove(r.lru.Back()))
+	}
+	r.mu.Unlock()
+
+	var err error
+	for _, entry := range entries {
+		if e := entry.orm.Close(ctx); e != nil && err == nil {
+			err = fmt.Errorf("tenant %s: %w", entry.tenant, e)
+		}
+	}
+	return err
+}
+
+// Opens the connection of tenant.
+func (r *TenantRouter) connect(tenant string) (*orm, error) {
+	conn, err := r.resolve(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn, err := tenantDSN(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.Connect(dsn, conn.Dialect, conn.Options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.Schema != "" {
+		err = db.Exec("CREATE SCHEMA IF NOT EXISTS " + quoteIdent(conn.Schema)).Error
+		if err != nil {
+			closeDB(db)
+			return nil, err
+		}
+	}
+
+	return newORM(db, dsn, conn.Dialect), nil
+}
+
+// Returns the connection of conn accepted by database.Connect, which takes
+// configs by pointer.
+func tenantDSN(conn *TenantConnection) (any, error) {
+	if conn.Schema != "" {
+		return withSearchPath(conn)
+	}
+
+	if config, ok := conn.DSN.(database.PostgresConfig); ok {
+		return &config, nil
+	}
+	return conn.DSN, nil
+}
+
+// Returns the postgres config of conn with the schema of the tenant as its
+// search path.
+func withSearchPath(conn *TenantConnection) (*database.PostgresConfig, error) {
+	if conn.Dialect != database.PG {
+		return nil, fmt.Errorf("schema is only valid when dialect is postgres")
+	}
+
+	var config database.PostgresConfig
+	switch dsn := conn.DSN.(type) {
+	case string:
+		c, err := database.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		config = *c
+	case database.PostgresConfig:
+		config = dsn
+	case *database.PostgresConfig:
+		config = *dsn
+	default:
+		return nil, fmt.Errorf("schema requires a dsn string or a database.PostgresConfig")
+	}
+
+	// Unquoted names in search_path are folded to lower case, while the
+	// schema is created with its name quoted.
+	config.SearchPath = quoteIdent(conn.Schema)
+	return &config, nil
+}
+
+// Quotes name as a postgres identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Removes e from the cache.
+func (r *TenantRouter) remove(e *list.Element) *tenantEntry {
+	entry := r.lru.Remove(e).(*tenantEntry)
+	delete(r.tenants, entry.tenant)
+	return entry
+}
+
+// Removes e from the cache and closes its connection once the operations in
+// flight on its ORM finish, without blocking the router.
+func (r *TenantRouter) evict(e *list.Element) {
+	entry := r.remove(e)
+	go entry.orm.Close(context.Background())
+}
+
+// Closes the connection pool of db.
+func closeDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,187 @@
+package realorm_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_TenantRouter(t *testing.T) {
+	dir := t.TempDir()
+	router := realorm.NewTenantRouter(realorm.SQLiteResolver(dir), 2)
+	defer router.Close(context.Background())
+
+	tenants := []string{"acme", "globex", "initech"}
+	if err := router.MigrateAll(tenants, &Post{}); err != nil {
+		t.Fatalf("error migrating tenants: %v\n", err)
+	}
+
+	for _, tenant := range tenants {
+		if _, err := os.Stat(filepath.Join(dir, tenant+".db")); err != nil {
+			t.Errorf("expected database file for %s: %v", tenant, err)
+		}
+	}
+
+	// the least recently used connection was closed
+	if router.Len() != 2 {
+		t.Errorf("expected 2 open connections, got %d", router.Len())
+	}
+
+	acme, err := router.ORM("acme")
+	if err != nil {
+		t.Fatalf("error getting tenant orm: %v\n", err)
+	}
+
+	if err := acme.Create(&Post{ID: UniqueID(), Title: "Acme", Content: "acme"}); err != nil {
+		t.Fatalf("error creating post: %v\n", err)
+	}
+
+	globex, err := router.ORM("globex")
+	if err != nil {
+		t.Fatalf("error getting tenant orm: %v\n", err)
+	}
+
+	// tenants do not share data
+	var posts []Post
+	if err := globex.FindAll(&posts, nil); err != nil {
+		t.Errorf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 0 {
+		t.Errorf("expected 0, got %d", len(posts))
+	}
+
+	if _, err := router.ORM("../escape"); err != realorm.ErrInvalidTenant {
+		t.Errorf("expected ErrInvalidTenant, got %v", err)
+	}
+
+	if err := router.Close(context.Background()); err != nil {
+		t.Errorf("error closing router: %v\n", err)
+	}
+
+	if router.Len() != 0 {
+		t.Errorf("expected 0 open connections, got %d", router.Len())
+	}
+
+	if _, err := router.ORM("acme"); !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed after close, got %v", err)
+	}
+}
+
+func Test_realorm_TenantRouterCloseDrains(t *testing.T) {
+	router := realorm.NewTenantRouter(realorm.SQLiteResolver(t.TempDir()), 0)
+
+	acme, err := router.ORM("acme")
+	if err != nil {
+		t.Fatalf("error getting tenant orm: %v\n", err)
+	}
+
+	if err := acme.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating acme: %v\n", err)
+	}
+
+	release := make(chan struct{})
+	done := blocked_transaction(acme, release)
+
+	// the transaction in flight holds Close until the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := router.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("error finishing transaction: %v\n", err)
+	}
+
+	var posts []Post
+	if err := acme.FindAll(&posts, nil); !errors.Is(err, realorm.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func Test_realorm_TenantRouterEviction(t *testing.T) {
+	router := realorm.NewTenantRouter(realorm.SQLiteResolver(t.TempDir()), 1)
+	defer router.Close(context.Background())
+
+	acme, err := router.ORM("acme")
+	if err != nil {
+		t.Fatalf("error getting tenant orm: %v\n", err)
+	}
+
+	if err := acme.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating acme: %v\n", err)
+	}
+
+	// a transaction in flight survives the eviction of its tenant
+	release := make(chan struct{})
+	done := blocked_transaction(acme, release)
+
+	if _, err := router.ORM("globex"); err != nil {
+		t.Fatalf("error getting tenant orm: %v\n", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("error finishing transaction of an evicted tenant: %v\n", err)
+	}
+
+	// then the evicted orm is closed
+	var posts []Post
+	for !errors.Is(acme.FindAll(&posts, nil), realorm.ErrClosed) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_realorm_TenantRouterConcurrentConnect(t *testing.T) {
+	dir := t.TempDir()
+	sqlite := realorm.SQLiteResolver(dir)
+
+	var resolved atomic.Int32
+	slow := make(chan struct{})
+	router := realorm.NewTenantRouter(func(tenant string) (*realorm.TenantConnection, error) {
+		if tenant == "slow" {
+			resolved.Add(1)
+			<-slow
+		}
+		return sqlite(tenant)
+	}, 0)
+	defer router.Close(context.Background())
+
+	orms := make(chan realorm.ORM, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			o, err := router.ORM("slow")
+			if err != nil {
+				t.Errorf("error getting tenant orm: %v\n", err)
+			}
+			orms <- o
+		}()
+	}
+
+	for resolved.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// other tenants do not wait for the slow connection
+	if _, err := router.ORM("fast"); err != nil {
+		t.Fatalf("error getting tenant orm: %v\n", err)
+	}
+
+	close(slow)
+	if first, second := <-orms, <-orms; first != second {
+		t.Errorf("expected the same orm for concurrent calls")
+	}
+
+	if resolved.Load() != 1 {
+		t.Errorf("expected the slow tenant to be connected once, got %d", resolved.Load())
+	}
+}
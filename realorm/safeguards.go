@@ -0,0 +1,284 @@
+package realorm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTooManyRows = errors.New("too many rows affected")
+	ErrTautology   = errors.New("where clause matches every row")
+)
+
+// Safeguards limit the damage of Update and Delete.
+type Safeguards struct {
+	// Update and Delete affecting more rows are rolled back and fail with
+	// ErrTooManyRows. Zero means no limit.
+	MaxRowsAffected int64
+	// Update and Delete fail with ErrTautology if their where clause is
+	// empty or always true, like "1 = 1" or "id IS NOT NULL", see
+	// IsTautology.
+	RejectTautologies bool
+}
+
+// WithSafeguards returns an ORM whose Update and Delete are guarded by s.
+func (o *orm) WithSafeguards(s Safeguards) ORM {
+	c := o.clone()
+	c.safeguards = &s
+	return c
+}
+
+// Checks the where clause of a destructive operation on model.
+func (o *orm) checkWhere(model any, where *WhereClause) error {
+	if o.safeguards != nil && o.safeguards.RejectTautologies && IsTautology(where.Query, notNullColumns(o.DB, model)...) {
+		return ErrTautology
+	}
+	return nil
+}
+
+// Returns the columns of model that cannot be NULL: its primary key and
+// the fields tagged not null.
+func notNullColumns(db *gorm.DB, model any) []string {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil
+	}
+
+	var columns []string
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName != "" && (field.PrimaryKey || field.NotNull) {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns
+}
+
+// Fails with ErrTooManyRows if result affected more rows than allowed.
+func (o *orm) checkRowsAffected(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if o.safeguards != nil && o.safeguards.MaxRowsAffected > 0 && result.RowsAffected > o.safeguards.MaxRowsAffected {
+		return fmt.Errorf("%w: %d rows, limit is %d", ErrTooManyRows, result.RowsAffected, o.safeguards.MaxRowsAffected)
+	}
+	return nil
+}
+
+// IsTautology returns true if the where clause query is empty or always
+// true, like "1", "1 = 1", "true", "id = id", "1=1 AND 2=2", "NOT 1=0" or an
+// OR with such a term. Placeholders are never considered equal.
+// Columns in notNull never hold NULL, so "id IS NOT NULL" and "name LIKE '%'"
+// are always true for them. Update and Delete pass the primary key and the
+// not null columns of their model.
+func IsTautology(query string, notNull ...string) bool {
+	query = trimParens(query)
+	if query == "" {
+		return true
+	}
+
+	columns := make(map[string]bool, len(notNull))
+	for _, column := range notNull {
+		columns[strings.ToLower(column)] = true
+	}
+
+	value, constant := evaluate(query, columns)
+	return constant && value
+}
+
+// Returns the value of the boolean expression expr and whether it has that
+// value for every row. Expressions depending on columns or placeholders are
+// not constant, unless they only test columns in notNull for NULL.
+func evaluate(expr string, notNull map[string]bool) (value bool, constant bool) {
+	expr = trimParens(expr)
+
+	if terms := splitTopLevel(expr, " or "); len(terms) > 1 {
+		constant = true
+		for _, term := range terms {
+			v, c := evaluate(term, notNull)
+			if c && v {
+				return true, true
+			}
+			constant = constant && c
+		}
+		return false, constant
+	}
+
+	if terms := splitTopLevel(expr, " and "); len(terms) > 1 {
+		constant = true
+		for _, term := range terms {
+			v, c := evaluate(term, notNull)
+			if c && !v {
+				return false, true
+			}
+			constant = constant && c
+		}
+		return true, constant
+	}
+
+	lower := strings.ToLower(expr)
+	if strings.HasPrefix(lower, "not ") || strings.HasPrefix(lower, "not(") {
+		v, c := evaluate(expr[3:], notNull)
+		return !v, c
+	}
+
+	return compare(lower, notNull)
+}
+
+// Evaluates a literal, a NULL test or a comparison, see evaluate.
+func compare(term string, notNull map[string]bool) (value bool, constant bool) {
+	switch term {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+
+	if n, err := strconv.ParseFloat(term, 64); err == nil {
+		return n != 0, true
+	}
+
+	if operand, ok := strings.CutSuffix(term, " is not null"); ok {
+		value, constant = isNull(operand, notNull)
+		return !value, constant
+	}
+
+	if operand, ok := strings.CutSuffix(term, " is null"); ok {
+		return isNull(operand, notNull)
+	}
+
+	for _, op := range []string{"<>", "!=", ">=", "<=", "=", ">", "<", " like "} {
+		parts := strings.SplitN(term, op, 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		left, right := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if left == "" || right == "" || strings.Contains(left, "?") || strings.Contains(right, "?") {
+			return false, false
+		}
+
+		// a pattern of only wildcards matches every value
+		if op == " like " && len(right) > 2 && right[0] == '\'' && strings.Trim(right, "'%") == "" {
+			if v, c := isNull(left, notNull); c && !v {
+				return true, true
+			}
+			return false, false
+		}
+
+		l, lerr := strconv.ParseFloat(left, 64)
+		r, rerr := strconv.ParseFloat(right, 64)
+		if lerr == nil && rerr == nil {
+			switch op {
+			case "<>", "!=":
+				return l != r, true
+			case ">=":
+				return l >= r, true
+			case "<=":
+				return l <= r, true
+			case "=", " like ":
+				return l == r, true
+			case ">":
+				return l > r, true
+			case "<":
+				return l < r, true
+			}
+		}
+
+		if left != right {
+			return false, false
+		}
+
+		switch op {
+		case "=", ">=", "<=", " like ":
+			return true, true
+		}
+		return false, true
+	}
+
+	return false, false
+}
+
+// Returns whether operand is NULL and whether it is for every row. Literals
+// and the columns in notNull are never NULL.
+func isNull(operand string, notNull map[string]bool) (value bool, constant bool) {
+	operand = trimParens(operand)
+	if operand == "null" {
+		return true, true
+	}
+
+	if _, err := strconv.ParseFloat(operand, 64); err == nil || (len(operand) > 1 && operand[0] == '\'') {
+		return false, true
+	}
+
+	// column, "column" or table.column
+	column := operand
+	if dot := strings.LastIndexByte(column, '.'); dot >= 0 {
+		column = column[dot+1:]
+	}
+
+	if notNull[strings.Trim(column, "`\"")] {
+		return false, true
+	}
+	return false, false
+}
+
+// Removes whitespace and parentheses enclosing the whole of s.
+func trimParens(s string) string {
+	s = strings.TrimSpace(s)
+	for len(s) > 1 && s[0] == '(' && s[len(s)-1] == ')' && closingParen(s) == len(s)-1 {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// Returns the index of the parenthesis closing the one at s[0].
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Splits s on sep, case-insensitively, outside parentheses and quotes.
+func splitTopLevel(s string, sep string) []string {
+	lower := strings.ToLower(s)
+
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(lower[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i = start - 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
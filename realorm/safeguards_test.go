@@ -0,0 +1,128 @@
+package realorm_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_IsTautology(t *testing.T) {
+	cases := map[string]bool{
+		"":                   true,
+		"1 = 1":              true,
+		"true":               true,
+		"id = id":            true,
+		"(1=1) OR title = ?": true,
+		"2 > 1":              true,
+		"id = ?":             false,
+		"? = ?":              false,
+		"1 = 2":              false,
+		"title = 'a or 1=1'": false,
+		"id = ? AND 1 = 1":   false,
+		"1=1 AND 1=1":        true,
+		"1=1 and 2=2":        true,
+		"NOT 1=0":            true,
+		"not (1 = 2)":        true,
+		"not false":          true,
+		"(1=1 AND 2=2) OR ?": true,
+		"1=1 AND 1=2":        false,
+		"NOT 1=1":            false,
+		"NOT id = ?":         false,
+		"id <> id OR 1=0":    false,
+	}
+
+	for query, expected := range cases {
+		if got := realorm.IsTautology(query); got != expected {
+			t.Errorf("IsTautology(%q) = %v, expected %v", query, got, expected)
+		}
+	}
+
+	// id and title are never NULL
+	notNull := map[string]bool{
+		"1":                                 true,
+		"id IS NOT NULL":                    true,
+		`"posts"."id" is not null`:          true,
+		"title LIKE '%'":                    true,
+		"title like '%%'":                   true,
+		"NOT id IS NULL":                    true,
+		"'a' IS NOT NULL":                   true,
+		"NULL IS NULL":                      true,
+		"id IS NULL":                        false,
+		"deleted_at IS NOT NULL":            false,
+		"deleted_at LIKE '%'":               false,
+		"title LIKE '%a%'":                  false,
+		"title LIKE ?":                      false,
+		"id IS NOT NULL AND title = ?":      false,
+		"id IS NOT NULL AND title LIKE '%'": true,
+	}
+
+	for query, expected := range notNull {
+		if got := realorm.IsTautology(query, "id", "title"); got != expected {
+			t.Errorf("IsTautology(%q, id, title) = %v, expected %v", query, got, expected)
+		}
+	}
+}
+
+func Test_realorm_Safeguards(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "safeguards.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	for _, id := range []uint{1, 2} {
+		if err := orm.Create(&Post{ID: id, Title: "Hello", Content: "World"}); err != nil {
+			t.Fatalf("error creating post: %v\n", err)
+		}
+	}
+
+	guarded := orm.WithSafeguards(realorm.Safeguards{MaxRowsAffected: 1, RejectTautologies: true})
+
+	for _, query := range []string{"1 = 1", "1=1 AND 2=2", "NOT 1=0", "1", "id IS NOT NULL", "title LIKE '%'"} {
+		err := guarded.Delete(&Post{}, &realorm.WhereClause{Query: query})
+		if !errors.Is(err, realorm.ErrTautology) {
+			t.Errorf("expected ErrTautology for %q, got %v", query, err)
+		}
+	}
+
+	// the not null columns of the updated model are known too
+	_, err := guarded.Update(Post{Title: "Updated"}, 1, &realorm.WhereClause{Query: "content LIKE '%'"})
+	if !errors.Is(err, realorm.ErrTautology) {
+		t.Errorf("expected ErrTautology for an update, got %v", err)
+	}
+
+	// the delete is rolled back
+	err = guarded.Delete(&Post{}, &realorm.WhereClause{Query: "id IN ?", Args: []interface{}{[]uint{1, 2}}})
+	if !errors.Is(err, realorm.ErrTooManyRows) {
+		t.Errorf("expected ErrTooManyRows, got %v", err)
+	}
+
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 2 {
+		t.Fatalf("expected 2 posts, got %d", len(posts))
+	}
+
+	// only the post with the given id is updated
+	_, err = guarded.Update(Post{Title: "Updated"}, 1, &realorm.WhereClause{Query: "title = ?", Args: []interface{}{"Hello"}})
+	if err != nil {
+		t.Fatalf("error updating post: %v\n", err)
+	}
+
+	post := Post{}
+	if err := orm.Find(&post, &realorm.WhereClause{Query: "id = ?", Args: []interface{}{2}}); err != nil {
+		t.Fatalf("error finding post: %v\n", err)
+	}
+
+	if post.Title != "Hello" {
+		t.Errorf("expected post 2 to be unchanged, got title %q", post.Title)
+	}
+
+	if err := guarded.Delete(&Post{}, &realorm.WhereClause{Query: "id = ?", Args: []interface{}{2}}); err != nil {
+		t.Errorf("error deleting post: %v\n", err)
+	}
+}
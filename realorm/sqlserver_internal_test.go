@@ -0,0 +1,79 @@
+package realorm
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/realorm/database"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+type order struct {
+	ID    uint   `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	Total int    `gorm:"not null"`
+	Note  string `gorm:"type:varchar(100)"`
+}
+
+// Returns an orm on a SQL Server database that is never connected to.
+func sqlServerORM(t *testing.T) *orm {
+	conn, err := sql.Open("sqlserver", "sqlserver://realorm@localhost")
+	if err != nil {
+		t.Fatalf("error opening database: %v\n", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := gorm.Open(sqlserver.New(sqlserver.Config{Conn: conn}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("error opening database: %v\n", err)
+	}
+	return newORM(db, "sqlserver://realorm@localhost", database.SQLSERVER)
+}
+
+func TestSQLServerPagination(t *testing.T) {
+	recorder := NewStatementRecorder()
+	dry := sqlServerORM(t).DryRun(recorder)
+
+	var orders []order
+	where := &WhereClause{Query: "total > ?", Args: []interface{}{10}}
+	if _, err := dry.FindAllPaginated(&orders, 3, 20, where); err != nil {
+		t.Fatalf("error paginating orders: %v\n", err)
+	}
+
+	statements := recorder.Statements()
+	if len(statements) != 2 {
+		t.Fatalf("expected 2 statements, got:\n%s", recorder.String())
+	}
+
+	expected := `SELECT * FROM "orders" WHERE total > @p1 ORDER BY "id" OFFSET 40 ROWS FETCH NEXT 20 ROWS ONLY`
+	if statements[1].SQL != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, statements[1].SQL)
+	}
+}
+
+func TestSQLServerUpsert(t *testing.T) {
+	recorder := NewStatementRecorder()
+	dry := sqlServerORM(t).DryRun(recorder)
+
+	if err := dry.Upsert(&order{ID: 7, Total: 12, Note: "paid"}); err != nil {
+		t.Fatalf("error upserting order: %v\n", err)
+	}
+
+	var merge string
+	for _, stmt := range recorder.Statements() {
+		if strings.HasPrefix(stmt.SQL, "MERGE") {
+			merge = stmt.SQL
+		}
+	}
+
+	for _, part := range []string{
+		`MERGE INTO "orders" USING (VALUES(@p1,@p2,@p3)) AS excluded ("total","note","id") ON "orders"."id" = "excluded"."id"`,
+		`WHEN MATCHED THEN UPDATE SET "total"="excluded"."total","note"="excluded"."note"`,
+		`WHEN NOT MATCHED THEN INSERT ("total","note") VALUES ("excluded"."total","excluded"."note") OUTPUT INSERTED."id"`,
+	} {
+		if !strings.Contains(merge, part) {
+			t.Errorf("expected MERGE containing:\n%s\ngot:\n%s", part, recorder.String())
+		}
+	}
+}
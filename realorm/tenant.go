@@ -0,0 +1,126 @@
+package realorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Name of the column that marks a model as tenant-aware.
+const TenantColumn = "tenant_id"
+
+var (
+	ErrNoTenant    = errors.New("tenant is required for tenant-aware models")
+	ErrOtherTenant = errors.New("row belongs to another tenant")
+)
+
+// ForTenant returns an ORM whose queries on tenant-aware models are
+// restricted to the tenant with the given id, including their preloaded
+// associations. Create stamps the tenant id on new models. Update and
+// Delete fail with gorm.ErrRecordNotFound if they match no row of the
+// tenant, so rows of other tenants look missing.
+func (o *orm) ForTenant(id any) ORM {
+	c := o.clone()
+	c.tenant = id
+	c.bypassTenant = false
+	return c
+}
+
+// WithoutTenant returns an ORM that runs queries on tenant-aware models
+// across all tenants.
+func (o *orm) WithoutTenant() ORM {
+	c := o.clone()
+	c.tenant = nil
+	c.bypassTenant = true
+	return c
+}
+
+// Returns a copy of the orm.
+func (o *orm) clone() *orm {
+	c := *o
+	return &c
+}
+
+// Returns true if model has a tenant column.
+func isTenantAware(db *gorm.DB, model any) bool {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return false
+	}
+
+	_, ok := stmt.Schema.FieldsByDBName[TenantColumn]
+	return ok
+}
+
+// Returns a scope adding the tenant predicate to queries on model.
+// It fails with ErrNoTenant if model is tenant-aware and the orm is neither
+// scoped to a tenant nor bypassing tenants.
+func (o *orm) tenantScope(model any) (func(*gorm.DB) *gorm.DB, error) {
+	if o.bypassTenant || !isTenantAware(o.DB, model) {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+
+	if o.tenant == nil {
+		return nil, ErrNoTenant
+	}
+
+	return o.tenantWhere, nil
+}
+
+// Adds the predicate on the tenant of the orm to db.
+func (o *orm) tenantWhere(db *gorm.DB) *gorm.DB {
+	return db.Where(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: TenantColumn},
+		Value:  o.tenant,
+	})
+}
+
+// Returns a scope preloading the associations of model. Associations on
+// tenant-aware models are restricted to the tenant of the orm like model
+// itself, and fail with ErrNoTenant if the orm has none.
+func (o *orm) preload(model any) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		stmt := &gorm.Statement{DB: db}
+		if o.bypassTenant || stmt.Parse(model) != nil {
+			return db.Preload(clause.Associations)
+		}
+
+		for name, rel := range stmt.Schema.Relationships.Relations {
+			if _, ok := rel.FieldSchema.FieldsByDBName[TenantColumn]; !ok {
+				db = db.Preload(name)
+			} else if o.tenant == nil {
+				db.AddError(ErrNoTenant)
+			} else {
+				db = db.Preload(name, o.tenantWhere)
+			}
+		}
+		return db
+	}
+}
+
+// Returns true if writes to model are restricted to the tenant of the orm.
+func (o *orm) scopedToTenant(model any) bool {
+	return o.tenant != nil && !o.bypassTenant && !o.dryRun && isTenantAware(o.DB, model)
+}
+
+// Sets the tenant column of model to the tenant of the orm.
+func (o *orm) stampTenant(model any) error {
+	if o.tenant == nil {
+		return nil
+	}
+
+	stmt := &gorm.Statement{DB: o.DB}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	field, ok := stmt.Schema.FieldsByDBName[TenantColumn]
+	if !ok {
+		return nil
+	}
+
+	return field.Set(context.Background(), reflect.ValueOf(model), o.tenant)
+}
@@ -0,0 +1,159 @@
+package realorm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/abiiranathan/realorm/database"
+	"github.com/abiiranathan/realorm/realorm"
+	"gorm.io/gorm"
+)
+
+type Invoice struct {
+	ID       uint   `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	TenantID uint   `gorm:"not null;index"`
+	Number   string `gorm:"type:varchar(100);not null"`
+}
+
+func Test_realorm_Tenant(t *testing.T) {
+	orm := realorm.New(database.SQLITE3_MEMORY_DB, testDialect)
+	if err := orm.Migrate(&Invoice{}, &Post{}); err != nil {
+		t.Fatalf("error migrating invoices: %v\n", err)
+	}
+
+	defer orm.GetDB().Exec("DELETE FROM invoices;")
+
+	acme := orm.ForTenant(uint(1))
+	globex := orm.ForTenant(uint(2))
+
+	invoice := &Invoice{ID: UniqueID(), TenantID: 2, Number: "INV-1"}
+	if err := acme.Create(invoice); err != nil {
+		t.Fatalf("error creating invoice: %v\n", err)
+	}
+
+	// the tenant is stamped on create
+	if invoice.TenantID != 1 {
+		t.Errorf("expected tenant 1, got %d", invoice.TenantID)
+	}
+
+	where := &realorm.WhereClause{Query: "id = ?", Args: []interface{}{invoice.ID}}
+
+	// unscoped queries are refused
+	if err := orm.Find(&Invoice{}, where); err != realorm.ErrNoTenant {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+
+	var invoices []Invoice
+	if err := orm.FindAll(&invoices, nil); err != realorm.ErrNoTenant {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+
+	// models without a tenant column are not affected
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Errorf("error finding posts: %v\n", err)
+	}
+
+	if err := acme.Find(&Invoice{}, where); err != nil {
+		t.Errorf("error finding invoice: %v\n", err)
+	}
+
+	if err := globex.Find(&Invoice{}, where); err == nil {
+		t.Errorf("expected error finding invoice of another tenant, got nil")
+	}
+
+	result, err := globex.FindAllPaginated(&invoices, 1, 10, nil)
+	if err != nil {
+		t.Fatalf("error paginating invoices: %v\n", err)
+	}
+
+	if result.Count != 0 {
+		t.Errorf("expected 0, got %d", result.Count)
+	}
+
+	if _, err := globex.Update(Invoice{Number: "INV-2"}, invoice.ID, where); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound updating invoice of another tenant, got %v", err)
+	}
+
+	// an update matching no row of the tenant fails
+	none := &realorm.WhereClause{Query: "number = ?", Args: []interface{}{"INV-0"}}
+	if _, err := acme.Update(Invoice{Number: "INV-2"}, invoice.ID, none); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound updating no row, got %v", err)
+	}
+
+	// the tenant can not be changed by an update
+	updated, err := acme.Update(Invoice{Number: "INV-2", TenantID: 2}, invoice.ID, where)
+	if err != nil {
+		t.Fatalf("error updating invoice: %v\n", err)
+	}
+
+	if inv := updated.(*Invoice); inv.Number != "INV-2" || inv.TenantID != 1 {
+		t.Errorf("unexpected invoice after update: %+v", inv)
+	}
+
+	if err := globex.Delete(&Invoice{}, where); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound deleting invoice of another tenant, got %v", err)
+	}
+
+	if err := orm.WithoutTenant().FindAll(&invoices, nil); err != nil {
+		t.Errorf("error finding invoices: %v\n", err)
+	}
+
+	if len(invoices) != 1 {
+		t.Errorf("expected 1, got %d", len(invoices))
+	}
+}
+
+type Order struct {
+	ID       uint `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	TenantID uint `gorm:"not null;index"`
+	Lines    []OrderLine
+}
+
+type OrderLine struct {
+	ID       uint `gorm:"primary_key;not null;AUTO_INCREMENT"`
+	TenantID uint `gorm:"not null;index"`
+	OrderID  uint
+}
+
+func Test_realorm_TenantPreload(t *testing.T) {
+	orm := realorm.New(database.SQLITE3_MEMORY_DB, testDialect)
+	if err := orm.Migrate(&Order{}, &OrderLine{}); err != nil {
+		t.Fatalf("error migrating orders: %v\n", err)
+	}
+
+	defer orm.GetDB().Exec("DELETE FROM order_lines;")
+	defer orm.GetDB().Exec("DELETE FROM orders;")
+
+	order := &Order{ID: UniqueID()}
+	if err := orm.ForTenant(uint(1)).Create(order); err != nil {
+		t.Fatalf("error creating order: %v\n", err)
+	}
+
+	// a line of another tenant pointing at the order
+	for _, line := range []*OrderLine{{ID: UniqueID(), TenantID: 1, OrderID: order.ID}, {ID: UniqueID(), TenantID: 2, OrderID: order.ID}} {
+		if err := orm.WithoutTenant().Create(line); err != nil {
+			t.Fatalf("error creating line: %v\n", err)
+		}
+	}
+
+	where := &realorm.WhereClause{Query: "id = ?", Args: []interface{}{order.ID}}
+
+	var found Order
+	if err := orm.ForTenant(uint(1)).Find(&found, where); err != nil {
+		t.Fatalf("error finding order: %v\n", err)
+	}
+
+	if len(found.Lines) != 1 || found.Lines[0].TenantID != 1 {
+		t.Errorf("expected only the line of the tenant, got %+v", found.Lines)
+	}
+
+	var orders []Order
+	if err := orm.ForTenant(uint(1)).FindAll(&orders, where); err != nil || len(orders) != 1 || len(orders[0].Lines) != 1 {
+		t.Errorf("expected only the line of the tenant, got %+v and %v", orders, err)
+	}
+
+	if err := orm.WithoutTenant().Find(&found, where); err != nil || len(found.Lines) != 2 {
+		t.Errorf("expected the lines of all tenants, got %+v and %v", found.Lines, err)
+	}
+}
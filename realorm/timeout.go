@@ -0,0 +1,13 @@
+package realorm
+
+import "time"
+
+// WithQueryTimeout returns an ORM cancelling each attempt of an operation
+// after d, unless its context expires earlier. The operations inside a
+// transaction are bounded on their own, the transaction as a whole is not.
+// Zero disables the timeout.
+func (o *orm) WithQueryTimeout(d time.Duration) ORM {
+	c := o.clone()
+	c.timeout = d
+	return c
+}
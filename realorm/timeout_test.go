@@ -0,0 +1,50 @@
+package realorm_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/realorm/database"
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_WithQueryTimeout(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "timeout.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	var posts []Post
+	err := orm.WithQueryTimeout(time.Nanosecond).FindAll(&posts, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if err := orm.WithQueryTimeout(time.Minute).FindAll(&posts, nil); err != nil {
+		t.Errorf("error finding posts: %v\n", err)
+	}
+}
+
+func Test_realorm_CircuitBreaker(t *testing.T) {
+	dir := t.TempDir()
+	orm := realorm.NewWithReplicas(filepath.Join(dir, "primary.db"), []any{filepath.Join(dir, "replica.db")},
+		testDialect, realorm.RoundRobin, database.WithCircuitBreaker(database.BreakerConfig{FailureThreshold: 1}))
+
+	breaker, ok := database.CircuitBreakerOf(orm.GetDB())
+	if !ok {
+		t.Fatalf("expected the primary to use a circuit breaker")
+	}
+
+	var posts []Post
+	if err := orm.UsePrimary().FindAll(&posts, nil); err == nil {
+		t.Fatalf("expected error finding posts before migrating")
+	}
+
+	// syntax errors do not open the circuit
+	if breaker.State() != database.BreakerClosed {
+		t.Errorf("expected the circuit to be closed, got %s", breaker.State())
+	}
+}
@@ -0,0 +1,219 @@
+package realorm
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tracer starts spans for ORM operations. Its shape follows the
+// OpenTelemetry trace API so an OpenTelemetry tracer can be adapted with a
+// thin wrapper.
+type Tracer interface {
+	// Start starts a span named name as a child of the span in ctx, if any,
+	// and returns a context holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+// Span attributes set on every operation
+const (
+	AttrOperation    = "db.operation"
+	AttrTable        = "db.sql.table"
+	AttrSystem       = "db.system"
+	AttrStatement    = "db.statement"
+	AttrRowsAffected = "db.rows_affected"
+)
+
+// NoopTracer is the default tracer. Its spans record nothing.
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value any) {}
+func (noopSpan) RecordError(err error)              {}
+func (noopSpan) End()                               {}
+
+type opSpanKey struct{}
+
+// opSpan collects the statements of an operation for its span.
+type opSpan struct {
+	span Span
+
+	mu         sync.Mutex
+	statements []string
+	rows       int64
+}
+
+// WithContext returns an ORM running its operations with ctx.
+// Spans of its operations are children of the span in ctx.
+func (o *orm) WithContext(ctx context.Context) ORM {
+	return o.withContext(ctx)
+}
+
+// Returns a copy of the orm whose statements carry ctx.
+func (o *orm) withContext(ctx context.Context) *orm {
+	c := o.clone()
+	c.ctx = ctx
+	c.DB = o.DB.WithContext(ctx)
+	return c
+}
+
+// Returns the context of the caller.
+func (o *orm) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// WithTracer returns an ORM tracing its operations with t.
+func (o *orm) WithTracer(t Tracer) ORM {
+	c := o.clone()
+	c.tracer = t
+	return c
+}
+
+// Starts the span of operation op on table.
+func (o *orm) startSpan(op string, table string) (context.Context, *opSpan) {
+	tracer := o.tracer
+	if tracer == nil {
+		tracer = NoopTracer{}
+	}
+
+	ctx, span := tracer.Start(o.context(), "realorm."+op)
+	span.SetAttribute(AttrOperation, op)
+	span.SetAttribute(AttrTable, table)
+	span.SetAttribute(AttrSystem, o.DB.Dialector.Name())
+
+	s := &opSpan{span: span}
+	return context.WithValue(ctx, opSpanKey{}, s), s
+}
+
+// Ends the span recording the statements of the operation and err.
+func (s *opSpan) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.span.SetAttribute(AttrStatement, strings.Join(s.statements, "; "))
+	s.span.SetAttribute(AttrRowsAffected, s.rows)
+
+	if err != nil {
+		s.span.RecordError(err)
+	}
+	s.span.End()
+}
+
+// Records the statement just executed by db in the span of its operation.
+// Statements hold placeholders, never the bound values.
+func traceStatement(db *gorm.DB) {
+	if db.Statement == nil || db.Statement.Context == nil {
+		return
+	}
+
+	s, ok := db.Statement.Context.Value(opSpanKey{}).(*opSpan)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sql := db.Statement.SQL.String(); sql != "" {
+		s.statements = append(s.statements, sql)
+	}
+	s.rows += db.RowsAffected
+}
+
+// RecordedSpan is a span kept by a SpanRecorder.
+type RecordedSpan struct {
+	ID         int
+	ParentID   int
+	Name       string
+	Attributes map[string]any
+	Errors     []error
+	Start      time.Time
+	End        time.Time
+}
+
+type recordedSpanKey struct{}
+
+// SpanRecorder is an in-memory Tracer for tests.
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// NewSpanRecorder returns an empty SpanRecorder.
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{}
+}
+
+func (r *SpanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	span := &RecordedSpan{
+		ID:         len(r.spans) + 1,
+		Name:       name,
+		Attributes: map[string]any{},
+		Start:      time.Now(),
+	}
+
+	if parent, ok := ctx.Value(recordedSpanKey{}).(*RecordedSpan); ok {
+		span.ParentID = parent.ID
+	}
+
+	r.spans = append(r.spans, span)
+	return context.WithValue(ctx, recordedSpanKey{}, span), &recorderSpan{r, span}
+}
+
+// Spans returns copies of the ended spans in the order they were started.
+func (r *SpanRecorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := []RecordedSpan{}
+	for _, s := range r.spans {
+		if !s.End.IsZero() {
+			spans = append(spans, *s)
+		}
+	}
+	return spans
+}
+
+type recorderSpan struct {
+	r    *SpanRecorder
+	span *RecordedSpan
+}
+
+func (s *recorderSpan) SetAttribute(key string, value any) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.span.Attributes[key] = value
+}
+
+func (s *recorderSpan) RecordError(err error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.span.Errors = append(s.span.Errors, err)
+}
+
+func (s *recorderSpan) End() {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.span.End = time.Now()
+}
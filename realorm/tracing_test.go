@@ -0,0 +1,97 @@
+package realorm_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_Tracing(t *testing.T) {
+	recorder := realorm.NewSpanRecorder()
+	orm := realorm.New(filepath.Join(t.TempDir(), "tracing.db"), testDialect).WithTracer(recorder)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	// operations are children of the span of the caller
+	ctx, parent := recorder.Start(context.Background(), "http.request")
+
+	err := orm.WithContext(ctx).Transaction(func(tx realorm.ORM) error {
+		return tx.Create(&Post{ID: UniqueID(), Title: "secret title", Content: "secret content"})
+	})
+
+	if err != nil {
+		t.Fatalf("error creating post: %v\n", err)
+	}
+
+	_ = orm.Find(&Post{}, &realorm.WhereClause{Query: "id = ?", Args: []interface{}{0}})
+	parent.End()
+
+	spans := recorder.Spans()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d: %+v", len(spans), spans)
+	}
+
+	request, transaction, create, find := spans[0], spans[1], spans[2], spans[3]
+
+	if request.Name != "http.request" || transaction.Name != "realorm.transaction" || create.Name != "realorm.create" {
+		t.Errorf("unexpected span names: %s, %s, %s", request.Name, transaction.Name, create.Name)
+	}
+
+	if transaction.ParentID != request.ID || create.ParentID != transaction.ID {
+		t.Errorf("unexpected span parents: %+v", spans)
+	}
+
+	if find.ParentID != 0 {
+		t.Errorf("expected a root span, got parent %d", find.ParentID)
+	}
+
+	if create.Attributes[realorm.AttrTable] != "posts" || create.Attributes[realorm.AttrSystem] != "sqlite" {
+		t.Errorf("unexpected attributes: %v", create.Attributes)
+	}
+
+	if create.Attributes[realorm.AttrRowsAffected] != int64(2) {
+		t.Errorf("expected 2 rows affected by insert and refetch, got %v", create.Attributes[realorm.AttrRowsAffected])
+	}
+
+	statement := create.Attributes[realorm.AttrStatement].(string)
+	if !strings.Contains(statement, "INSERT INTO `posts`") || strings.Contains(statement, "secret") {
+		t.Errorf("expected sanitized insert statement, got %s", statement)
+	}
+
+	if len(find.Errors) != 1 {
+		t.Errorf("expected the not found error to be recorded, got %v", find.Errors)
+	}
+}
+
+// Run with -race: adding a tracer to an ORM serving queries must not
+// modify its database.
+func Test_realorm_WithTracerConcurrent(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "tracing.db"), testDialect)
+	if err := orm.Migrate(&Post{}); err != nil {
+		t.Fatalf("error migrating posts: %v\n", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			var posts []Post
+			if err := orm.FindAll(&posts, nil); err != nil {
+				t.Errorf("error finding posts: %v\n", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		var posts []Post
+		if err := orm.WithTracer(realorm.NewSpanRecorder()).FindAll(&posts, nil); err != nil {
+			t.Errorf("error finding posts: %v\n", err)
+		}
+	}
+	<-done
+}
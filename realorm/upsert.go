@@ -0,0 +1,106 @@
+package realorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/abiiranathan/realorm/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Inserts model, or updates all columns of the row with its primary key.
+// Postgres and sqlite use ON CONFLICT, mysql ON DUPLICATE KEY UPDATE and
+// SQL Server a MERGE statement, which inserts new rows of an auto-increment
+// key with a generated key.
+func (o *orm) upsert(model any) error {
+	if features, ok := database.FeaturesOf(o.dialect); ok && features.Upsert == database.UpsertNone {
+		return fmt.Errorf("upsert is not supported by %s", o.dialect)
+	}
+
+	if err := o.stampTenant(model); err != nil {
+		return err
+	}
+
+	tenant, err := o.tenantScope(model)
+	if err != nil {
+		return err
+	}
+
+	stmt := &gorm.Statement{DB: o.DB}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return o.create(model)
+	}
+
+	err = o.inTransaction(func(tx *gorm.DB) error {
+		// rows archived before the upsert, none if it inserts
+		archived := 0
+
+		key, zero := field.ValueOf(context.Background(), reflect.ValueOf(model))
+		if !zero {
+			pk, err := primaryKey(tx, model, key)
+			if err != nil {
+				return err
+			}
+
+			if err := o.checkOwner(tx, model, pk); err != nil {
+				return err
+			}
+
+			if isHistorical(model) {
+				if archived, err = archive(tx, model, &WhereClause{}, HistoryUpdate, tenant); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(model).Error; err != nil {
+			return err
+		}
+
+		if isHistorical(model) && archived == 0 {
+			return recordCreate(tx, model)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	o.written = true
+	o.invalidate(model)
+
+	// refetch the model by its primary key
+	return o.DB.Scopes(tenant, o.preload(model)).First(model).Error
+}
+
+// Fails with ErrOtherTenant if the row of a tenant-aware model matched by
+// pk belongs to another tenant than the one of the orm, so an upsert
+// never overwrites it.
+func (o *orm) checkOwner(tx *gorm.DB, model any, pk clause.Expression) error {
+	if o.tenant == nil || !isTenantAware(tx, model) {
+		return nil
+	}
+
+	var count int64
+	err := tx.Model(model).Where(pk).Not(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: TenantColumn},
+		Value:  o.tenant,
+	}).Count(&count).Error
+
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return ErrOtherTenant
+	}
+	return nil
+}
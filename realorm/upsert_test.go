@@ -0,0 +1,85 @@
+package realorm_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/abiiranathan/realorm/realorm"
+)
+
+func Test_realorm_Upsert(t *testing.T) {
+	orm := realorm.New(filepath.Join(t.TempDir(), "upsert.db"), testDialect)
+	if err := orm.Migrate(&Post{}, &Article{}, &Invoice{}); err != nil {
+		t.Fatalf("error migrating: %v\n", err)
+	}
+
+	post := &Post{ID: UniqueID(), Title: "Draft", Content: "Hello"}
+	if err := orm.Upsert(post); err != nil {
+		t.Fatalf("error inserting post: %v\n", err)
+	}
+
+	if err := orm.Upsert(&Post{ID: post.ID, Title: "Published", Content: "Hello"}); err != nil {
+		t.Fatalf("error updating post: %v\n", err)
+	}
+
+	var posts []Post
+	if err := orm.FindAll(&posts, nil); err != nil {
+		t.Fatalf("error finding posts: %v\n", err)
+	}
+
+	if len(posts) != 1 || posts[0].Title != "Published" {
+		t.Errorf("expected 1 published post, got %+v", posts)
+	}
+
+	// constraint violations are translated
+	err := orm.Create(&Post{ID: post.ID, Title: "Copy", Content: "Hello"})
+	if !errors.Is(err, realorm.ErrDuplicateKey) {
+		t.Errorf("expected ErrDuplicateKey, got %v", err)
+	}
+
+	// the replaced version of historical models is archived
+	article := &Article{ID: UniqueID(), Title: "First"}
+	if err := orm.Upsert(article); err != nil {
+		t.Fatalf("error inserting article: %v\n", err)
+	}
+
+	if err := orm.Upsert(&Article{ID: article.ID, Title: "Second"}); err != nil {
+		t.Fatalf("error updating article: %v\n", err)
+	}
+
+	entries, err := orm.History(&Article{}, article.ID)
+	if err != nil {
+		t.Fatalf("error loading history: %v\n", err)
+	}
+
+	if len(entries) != 1 || entries[0].Operation != realorm.HistoryUpdate {
+		t.Errorf("expected 1 update in history, got %+v", entries)
+	}
+
+	// the insert recorded the creation of the article
+	if len(entries) == 1 && entries[0].ValidFrom.IsZero() {
+		t.Errorf("expected the first version to start at its creation, got zero")
+	}
+
+	// rows of other tenants are never overwritten
+	invoice := &Invoice{ID: UniqueID(), Number: "INV-1"}
+	if err := orm.ForTenant(uint(1)).Upsert(invoice); err != nil {
+		t.Fatalf("error inserting invoice: %v\n", err)
+	}
+
+	err = orm.ForTenant(uint(2)).Upsert(&Invoice{ID: invoice.ID, Number: "INV-2"})
+	if !errors.Is(err, realorm.ErrOtherTenant) {
+		t.Errorf("expected ErrOtherTenant, got %v", err)
+	}
+
+	var saved Invoice
+	where := &realorm.WhereClause{Query: "id = ?", Args: []interface{}{invoice.ID}}
+	if err := orm.ForTenant(uint(1)).Find(&saved, where); err != nil {
+		t.Fatalf("error finding invoice: %v\n", err)
+	}
+
+	if saved.Number != "INV-1" || saved.TenantID != 1 {
+		t.Errorf("expected INV-1 of tenant 1, got %+v", saved)
+	}
+}